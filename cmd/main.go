@@ -1,10 +1,23 @@
 package main
 
 import (
-	"github.com/selectel/docker-machine-driver/driver"
+	"fmt"
+	"os"
+
 	"github.com/docker/machine/libmachine/drivers/plugin"
+	"github.com/selectel/docker-machine-driver/driver"
 )
 
 func main() {
+	// docker-machine starts the plugin without arguments,
+	// anything else is a driver command run by the user
+	if len(os.Args) > 1 {
+		if err := driver.RunCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	plugin.RegisterDriver(driver.NewDriver("", ""))
-}
\ No newline at end of file
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v2/volumes"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+const (
+	defaultBackupPrefix = "docker-machine"
+	backupTimeFormat    = "20060102-150405"
+
+	errorForeignBackup = "Backup '%s' wasn't made for machine '%s' with prefix '%s', see the backups command for its backups"
+)
+
+// backupNamePrefix returns a common name prefix of all backups
+// made for the volume of the machine.
+func (d *Driver) backupNamePrefix(prefix, volumeID string) string {
+	return fmt.Sprintf("%s-%s-%s-", prefix, d.MachineName, volumeID)
+}
+
+// backupVolumes creates backups of all volumes attached to the machine
+// server and waits until they are available. Incremental backups contain
+// only the changes since the previous backup of the volume.
+func (d *Driver) backupVolumes(prefix string, incremental bool) ([]openstack.Backup, error) {
+	volumeIDs, err := d.client.GetServerVolumes(d.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumeIDs) == 0 {
+		return nil, fmt.Errorf("server '%s' has no volumes to backup", d.ServerID)
+	}
+
+	backups := make([]openstack.Backup, 0, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		opts := openstack.BackupCreateOpts{
+			VolumeID:    volumeID,
+			Name:        d.backupNamePrefix(prefix, volumeID) + time.Now().UTC().Format(backupTimeFormat),
+			Description: fmt.Sprintf("Backup of docker machine %s", d.MachineName),
+			Incremental: incremental,
+			Force:       true,
+		}
+
+		log.Infof("Creating backup '%s'...", opts.Name)
+		backup, err := d.client.CreateBackup(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := d.client.WaitForBackupStatus(backup.ID, "available"); err != nil {
+			return nil, err
+		}
+		backups = append(backups, *backup)
+	}
+	return backups, nil
+}
+
+// machineBackups returns backups of the machine volumes made with the given
+// prefix, newest first.
+func (d *Driver) machineBackups(prefix string) ([]openstack.Backup, error) {
+	allBackups, err := d.client.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []openstack.Backup
+	for _, backup := range allBackups {
+		if strings.HasPrefix(backup.Name, d.backupNamePrefix(prefix, backup.VolumeID)) {
+			backups = append(backups, backup)
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// backupChains groups backups of every volume into chains of a full backup
+// and the incremental backups made after it, newest chain first. Cinder
+// doesn't delete a backup which later incremental backups depend on, so
+// a chain is pruned as a whole. The backups are expected newest first.
+func backupChains(backups []openstack.Backup) [][]openstack.Backup {
+	var chains [][]openstack.Backup
+	current := make(map[string]int)
+	for i := len(backups) - 1; i >= 0; i-- {
+		backup := backups[i]
+		index, ok := current[backup.VolumeID]
+		if !ok || !backup.Incremental {
+			index = len(chains)
+			current[backup.VolumeID] = index
+			chains = append(chains, nil)
+		}
+		chains[index] = append(chains[index], backup)
+	}
+
+	for i, j := 0, len(chains)-1; i < j; i, j = i+1, j-1 {
+		chains[i], chains[j] = chains[j], chains[i]
+	}
+	return chains
+}
+
+// pruneBackups removes backups of every machine volume beyond the newest
+// keep chains or with the newest backup older than maxAge. A chain is a full
+// backup with its incremental backups, backups of a chain are removed newest
+// first. Chains with backups which aren't available are left as is. Zero
+// values disable the limits.
+func (d *Driver) pruneBackups(prefix string, keep int, maxAge time.Duration) error {
+	backups, err := d.machineBackups(prefix)
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[string]int)
+	for _, chain := range backupChains(backups) {
+		if !backupsAvailable(chain) {
+			continue
+		}
+
+		newest := chain[len(chain)-1]
+		kept[newest.VolumeID]++
+		tooMany := keep > 0 && kept[newest.VolumeID] > keep
+		tooOld := maxAge > 0 && time.Since(newest.CreatedAt) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		for i := len(chain) - 1; i >= 0; i-- {
+			backup := chain[i]
+			log.Infof("Removing backup '%s' created at %s...", backup.Name, backup.CreatedAt.Format(time.RFC3339))
+			if err := d.client.DeleteBackup(backup.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func backupsAvailable(backups []openstack.Backup) bool {
+	for _, backup := range backups {
+		if backup.Status != "available" {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreBackup restores the backup into a new volume which may be used
+// as a boot volume of the machine. The volume is created in advance to get
+// the volume type and availability zone of the machine volume. Only backups
+// of the machine made with the prefix are restored.
+func (d *Driver) restoreBackup(backupID, prefix, volumeName string) (string, error) {
+	backup, err := d.client.GetBackup(backupID)
+	if err != nil {
+		return "", err
+	}
+	if backup.Status != "available" {
+		return "", fmt.Errorf("backup '%s' is not available, current status is '%s'", backupID, backup.Status)
+	}
+	if !strings.HasPrefix(backup.Name, d.backupNamePrefix(prefix, backup.VolumeID)) {
+		return "", fmt.Errorf(errorForeignBackup, backupID, d.MachineName, prefix)
+	}
+
+	if err := d.resolveVolumeType(); err != nil {
+		return "", err
+	}
+
+	volumeOpts := volumes.CreateOpts{
+		Name:             volumeName,
+		VolumeType:       d.VolumeType,
+		Size:             backup.Size,
+		AvailabilityZone: d.AvailabilityZone,
+	}
+	volume, err := d.client.CreateVolume(volumeOpts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.restoreIntoVolume(backup, volume.ID, volumeName); err != nil {
+		// don't leave an empty or half-restored volume behind
+		if deleteErr := d.client.DeleteVolume(volume.ID); deleteErr != nil {
+			log.Errorf("Can't remove volume with id '%s': %s", volume.ID, deleteErr)
+		}
+		return "", err
+	}
+	return volume.ID, nil
+}
+
+// restoreIntoVolume restores the backup into the created volume and waits
+// until the restore finishes.
+func (d *Driver) restoreIntoVolume(backup *openstack.Backup, volumeID, volumeName string) error {
+	if err := d.client.WaitForVolumeAvailable(volumeID); err != nil {
+		return err
+	}
+
+	log.Infof("Restoring backup '%s' into a new volume '%s'...", backup.Name, volumeName)
+	restoreOpts := openstack.BackupRestoreOpts{
+		VolumeID: volumeID,
+	}
+	if _, err := d.client.RestoreBackup(backup.ID, restoreOpts); err != nil {
+		return err
+	}
+	return d.client.WaitForVolumeAvailable(volumeID)
+}
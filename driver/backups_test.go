@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+// backupsClient lists the given backups and records the deleted ones,
+// other methods of the client aren't implemented.
+type backupsClient struct {
+	openstack.Client
+	backups []openstack.Backup
+	deleted []string
+}
+
+func (client *backupsClient) ListBackups() ([]openstack.Backup, error) {
+	return client.backups, nil
+}
+
+func (client *backupsClient) DeleteBackup(backupID string) error {
+	client.deleted = append(client.deleted, backupID)
+	return nil
+}
+
+func TestPruneBackups(t *testing.T) {
+	d := NewDriver("machine", "")
+	now := time.Now()
+	backup := func(id string, incremental bool, age time.Duration) openstack.Backup {
+		return openstack.Backup{
+			ID:          id,
+			Name:        d.backupNamePrefix(defaultBackupPrefix, "volume") + id,
+			Status:      "available",
+			VolumeID:    "volume",
+			Incremental: incremental,
+			CreatedAt:   now.Add(-age),
+		}
+	}
+	// two chains of a full backup followed by incremental ones
+	backups := []openstack.Backup{
+		backup("full-1", false, 72*time.Hour),
+		backup("incr-1a", true, 60*time.Hour),
+		backup("incr-1b", true, 48*time.Hour),
+		backup("full-2", false, 36*time.Hour),
+		backup("incr-2a", true, 24*time.Hour),
+		backup("incr-2b", true, 12*time.Hour),
+	}
+
+	tests := []struct {
+		keep     int
+		maxAge   time.Duration
+		expected []string
+	}{
+		{
+			keep:     1,
+			expected: []string{"incr-1b", "incr-1a", "full-1"},
+		},
+		{
+			keep:     2,
+			expected: nil,
+		},
+		{
+			maxAge:   54 * time.Hour,
+			expected: nil,
+		},
+		{
+			maxAge:   42 * time.Hour,
+			expected: []string{"incr-1b", "incr-1a", "full-1"},
+		},
+		{
+			maxAge:   6 * time.Hour,
+			expected: []string{"incr-2b", "incr-2a", "full-2", "incr-1b", "incr-1a", "full-1"},
+		},
+	}
+
+	for _, test := range tests {
+		client := &backupsClient{backups: backups}
+		d.client = client
+		if err := d.pruneBackups(defaultBackupPrefix, test.keep, test.maxAge); err != nil {
+			t.Errorf("pruneBackups(%d, %s) returned error: %s", test.keep, test.maxAge, err)
+			continue
+		}
+		if !reflect.DeepEqual(client.deleted, test.expected) {
+			t.Errorf("pruneBackups(%d, %s) deleted %q, expected %q", test.keep, test.maxAge, client.deleted, test.expected)
+		}
+	}
+}
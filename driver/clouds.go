@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+	"gopkg.in/yaml.v2"
+)
+
+const errorUnknownCloud = "Cloud '%s' isn't found in %s"
+
+// cloudsConfig is the clouds.yaml or secure.yaml file shared with
+// the openstack CLI and other OpenStack tools.
+type cloudsConfig struct {
+	Clouds map[string]cloudConfig `yaml:"clouds"`
+}
+
+type cloudConfig struct {
+	Auth       cloudAuth `yaml:"auth"`
+	RegionName string    `yaml:"region_name"`
+	CACert     string    `yaml:"cacert"`
+	Cert       string    `yaml:"cert"`
+	Key        string    `yaml:"key"`
+	Verify     *bool     `yaml:"verify"`
+}
+
+type cloudAuth struct {
+	AuthURL                     string `yaml:"auth_url"`
+	Username                    string `yaml:"username"`
+	Password                    string `yaml:"password"`
+	Token                       string `yaml:"token"`
+	ApplicationCredentialID     string `yaml:"application_credential_id"`
+	ApplicationCredentialName   string `yaml:"application_credential_name"`
+	ApplicationCredentialSecret string `yaml:"application_credential_secret"`
+	ProjectID                   string `yaml:"project_id"`
+	ProjectName                 string `yaml:"project_name"`
+	DomainName                  string `yaml:"domain_name"`
+	UserDomainName              string `yaml:"user_domain_name"`
+	UserDomainID                string `yaml:"user_domain_id"`
+	ProjectDomainName           string `yaml:"project_domain_name"`
+	ProjectDomainID             string `yaml:"project_domain_id"`
+}
+
+// cloudConfigPaths returns the standard locations of the file, the first
+// existing one is used.
+func cloudConfigPaths(name string) []string {
+	paths := []string{name}
+	if name == "clouds.yaml" {
+		if path := os.Getenv("OS_CLIENT_CONFIG_FILE"); path != "" {
+			paths = []string{path}
+		}
+	}
+	return append(paths,
+		filepath.Join(mcnutils.GetHomeDir(), ".config", "openstack", name),
+		filepath.Join("/etc", "openstack", name),
+	)
+}
+
+// readCloudsConfig reads the first existing file of the given paths,
+// missing files are skipped.
+func readCloudsConfig(paths []string) (*cloudsConfig, error) {
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var config cloudsConfig
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return &config, nil
+	}
+	return &cloudsConfig{}, nil
+}
+
+// loadCloudConfig fills the settings which weren't given explicitly from
+// the named cloud. Values of secure.yaml take precedence over clouds.yaml.
+func (d *Driver) loadCloudConfig() error {
+	cloudsPaths := cloudConfigPaths("clouds.yaml")
+	clouds, err := readCloudsConfig(cloudsPaths)
+	if err != nil {
+		return err
+	}
+	secure, err := readCloudsConfig(cloudConfigPaths("secure.yaml"))
+	if err != nil {
+		return err
+	}
+
+	cloud, ok := clouds.Clouds[d.Cloud]
+	if !ok {
+		return fmt.Errorf(errorUnknownCloud, d.Cloud, strings.Join(cloudsPaths, ", "))
+	}
+
+	d.applyCloudConfig(secure.Clouds[d.Cloud])
+	d.applyCloudConfig(cloud)
+	return nil
+}
+
+// applyCloudConfig sets the settings which are still empty.
+func (d *Driver) applyCloudConfig(cloud cloudConfig) {
+	setDefault := func(value *string, defaults ...string) {
+		for _, defaultValue := range defaults {
+			if *value == "" {
+				*value = defaultValue
+			}
+		}
+	}
+
+	// an id and a name of the same thing can't be combined, the id wins
+	setEither := func(id, name *string, idValue, nameValue string) {
+		if *id != "" || *name != "" {
+			return
+		}
+		if idValue != "" {
+			*id = idValue
+		} else {
+			*name = nameValue
+		}
+	}
+
+	auth := cloud.Auth
+	setDefault(&d.AuthUrl, auth.AuthURL)
+	setDefault(&d.Username, auth.Username)
+	setDefault(&d.Password, auth.Password)
+	setDefault(&d.Token, auth.Token)
+	setDefault(&d.ApplicationCredentialID, auth.ApplicationCredentialID)
+	setDefault(&d.ApplicationCredentialName, auth.ApplicationCredentialName)
+	setDefault(&d.ApplicationCredentialSecret, auth.ApplicationCredentialSecret)
+	setEither(&d.ProjectID, &d.ProjectName, auth.ProjectID, auth.ProjectName)
+	setEither(&d.UserDomainID, &d.UserDomainName, auth.UserDomainID, auth.UserDomainName)
+	setEither(&d.ProjectDomainID, &d.ProjectDomainName, auth.ProjectDomainID, auth.ProjectDomainName)
+	setDefault(&d.DomainName, auth.DomainName)
+	setDefault(&d.Region, cloud.RegionName)
+	setDefault(&d.CACert, cloud.CACert)
+	setDefault(&d.ClientCert, cloud.Cert)
+	setDefault(&d.ClientKey, cloud.Key)
+	if cloud.Verify != nil && !*cloud.Verify {
+		d.Insecure = true
+	}
+}
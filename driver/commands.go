@@ -0,0 +1,348 @@
+package driver
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+// command is a maintenance action which is run by invoking the driver binary
+// directly, e.g. `docker-machine-driver-selectel retype my-machine fast`.
+type command struct {
+	usage       string
+	description string
+	run         func(flags *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]command{
+	"backup": {
+		usage:       "backup [options] <machine>",
+		description: "Back up all volumes of a machine and prune old backups",
+		run:         runBackup,
+	},
+	"backups": {
+		usage:       "backups [options] <machine>",
+		description: "List backups of a machine",
+		run:         runBackups,
+	},
+	"list": {
+		usage:       "list [options] <flavors|images|networks|volume-types|zones>",
+		description: "List project resources which may be used to create a machine",
+		run:         runList,
+	},
+	"restore": {
+		usage:       "restore [options] <machine> <backup-id>",
+		description: "Restore a machine backup into a new boot volume",
+		run:         runRestore,
+	},
+	"retype": {
+		usage:       "retype [options] <machine> <volume-type>",
+		description: "Change volume type of a machine, e.g. from basic to fast storage",
+		run:         runRetype,
+	},
+}
+
+// RunCommand runs the driver command given by the command line arguments.
+func RunCommand(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCommands()
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printCommands()
+		return fmt.Errorf("unknown command '%s'", args[0])
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s\n", cmd.usage)
+		flags.PrintDefaults()
+	}
+
+	err := cmd.run(flags, args[1:])
+	if err == flag.ErrHelp {
+		return nil
+	}
+	return err
+}
+
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].description)
+	}
+}
+
+// storagePathFlag adds an option shared by all commands which operate
+// on stored machines.
+func storagePathFlag(flags *flag.FlagSet) *string {
+	return flags.String("storage-path", defaultStoragePath(), "Docker Machine storage path")
+}
+
+// authFlags adds the create flags needed to authenticate to commands which
+// don't operate on stored machines. Like docker-machine, unset flags fall
+// back to their environment variables.
+func authFlags(flags *flag.FlagSet) flagOptions {
+	createFlags := make(map[string]mcnflag.Flag)
+	for _, createFlag := range NewDriver("", "").GetCreateFlags() {
+		createFlags[createFlag.String()] = createFlag
+	}
+
+	opts := flagOptions{flags: flags, envVars: make(map[string]string)}
+	for _, name := range authFlagNames {
+		switch createFlag := createFlags[name].(type) {
+		case mcnflag.StringFlag:
+			flags.String(name, createFlag.Value, fmt.Sprintf("%s [$%s]", createFlag.Usage, createFlag.EnvVar))
+			opts.envVars[name] = createFlag.EnvVar
+		case mcnflag.BoolFlag:
+			flags.Bool(name, false, fmt.Sprintf("%s [$%s]", createFlag.Usage, createFlag.EnvVar))
+			opts.envVars[name] = createFlag.EnvVar
+		}
+	}
+	return opts
+}
+
+// flagOptions provides parsed command flags as driver options.
+type flagOptions struct {
+	flags   *flag.FlagSet
+	envVars map[string]string
+}
+
+func (opts flagOptions) String(key string) string {
+	f := opts.flags.Lookup(key)
+	if f == nil {
+		return ""
+	}
+
+	isSet := false
+	opts.flags.Visit(func(f *flag.Flag) {
+		isSet = isSet || f.Name == key
+	})
+	if env := os.Getenv(opts.envVars[key]); !isSet && env != "" {
+		return env
+	}
+	return f.Value.String()
+}
+
+func (opts flagOptions) StringSlice(key string) []string {
+	if value := opts.String(key); value != "" {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
+func (opts flagOptions) Int(key string) int {
+	value, _ := strconv.Atoi(opts.String(key))
+	return value
+}
+
+func (opts flagOptions) Bool(key string) bool {
+	value, _ := strconv.ParseBool(opts.String(key))
+	return value
+}
+
+func loadAuthenticatedMachine(storagePath, name string) (*Driver, error) {
+	d, err := loadMachine(storagePath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Authenticate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func runRetype(flags *flag.FlagSet, args []string) error {
+	storagePath := storagePathFlag(flags)
+	migrationPolicy := flags.String("migration-policy", openstack.MigrationPolicyOnDemand, "Cinder migration policy: on-demand or never")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("machine name and volume type are required")
+	}
+	if *migrationPolicy != openstack.MigrationPolicyOnDemand && *migrationPolicy != openstack.MigrationPolicyNever {
+		return fmt.Errorf("migration policy '%s' is unknown, use '%s' or '%s'",
+			*migrationPolicy, openstack.MigrationPolicyOnDemand, openstack.MigrationPolicyNever)
+	}
+
+	d, err := loadAuthenticatedMachine(*storagePath, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if d.VolumeID == "" {
+		return fmt.Errorf("machine '%s' boots from a local disk and has no volume", d.MachineName)
+	}
+
+	currentType := d.VolumeType
+	d.VolumeType = flags.Arg(1)
+	if err := d.resolveVolumeType(); err != nil {
+		return err
+	}
+
+	if d.VolumeType == currentType {
+		log.Infof("Volume '%s' already has type '%s'", d.VolumeID, d.VolumeType)
+		return nil
+	}
+
+	log.Infof("Changing type of volume '%s' from '%s' to '%s'...", d.VolumeID, currentType, d.VolumeType)
+	opts := openstack.RetypeOpts{
+		NewType:         d.VolumeType,
+		MigrationPolicy: *migrationPolicy,
+	}
+	if err := d.client.RetypeVolume(d.VolumeID, opts); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for volume retype to finish...")
+	if err := d.client.WaitForVolumeType(d.VolumeID, d.VolumeType); err != nil {
+		return err
+	}
+
+	log.Infof("Volume '%s' now has type '%s'", d.VolumeID, d.VolumeType)
+	return saveMachine(d)
+}
+
+func runBackup(flags *flag.FlagSet, args []string) error {
+	storagePath := storagePathFlag(flags)
+	prefix := flags.String("prefix", defaultBackupPrefix, "Name prefix of the backups")
+	keep := flags.Int("keep", 0, "Number of backups to keep for every volume, 0 keeps all")
+	maxAge := flags.Duration("max-age", 0, "Remove backups older than the given duration, e.g. 720h")
+	incremental := flags.Bool("incremental", false, "Back up only the changes since the previous backup of every volume")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("machine name is required")
+	}
+
+	d, err := loadAuthenticatedMachine(*storagePath, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	backups, err := d.backupVolumes(*prefix, *incremental)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		log.Infof("Created backup '%s' with id '%s'", backup.Name, backup.ID)
+	}
+	return d.pruneBackups(*prefix, *keep, *maxAge)
+}
+
+func runBackups(flags *flag.FlagSet, args []string) error {
+	storagePath := storagePathFlag(flags)
+	prefix := flags.String("prefix", defaultBackupPrefix, "Name prefix of the backups")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("machine name is required")
+	}
+
+	d, err := loadAuthenticatedMachine(*storagePath, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	backups, err := d.machineBackups(*prefix)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tVOLUME\tSIZE\tSTATUS\tCREATED")
+	for _, backup := range backups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", backup.ID, backup.Name, backup.VolumeID,
+			backup.Size, backup.Status, backup.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runRestore(flags *flag.FlagSet, args []string) error {
+	storagePath := storagePathFlag(flags)
+	prefix := flags.String("prefix", defaultBackupPrefix, "Name prefix of the backups")
+	volumeName := flags.String("volume-name", "", "Name of the new volume")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("machine name and backup id are required")
+	}
+
+	d, err := loadAuthenticatedMachine(*storagePath, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *volumeName == "" {
+		*volumeName = fmt.Sprintf("%s restored at %s", d.VolumeName, time.Now().UTC().Format(backupTimeFormat))
+	}
+
+	volumeID, err := d.restoreBackup(flags.Arg(1), *prefix, *volumeName)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Backup restored into volume with id '%s'", volumeID)
+	return nil
+}
+
+func runList(flags *flag.FlagSet, args []string) error {
+	opts := authFlags(flags)
+	format := flags.String("format", listFormatTable, "Output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("resource kind is required")
+	}
+
+	list, ok := inventories[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("resource kind '%s' is unknown, use one of: %s", flags.Arg(0), strings.Join(inventoryNames(), ", "))
+	}
+
+	d := NewDriver("", "")
+	if err := d.setAuthConfigFromFlags(opts); err != nil {
+		return err
+	}
+	if err := d.checkAuthConfig(); err != nil {
+		return err
+	}
+	if err := d.Authenticate(); err != nil {
+		return err
+	}
+
+	inv, err := list(d.client)
+	if err != nil {
+		return err
+	}
+	return inv.print(os.Stdout, *format)
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	errorNoCredentials       = "No %s is available for machine '%s', set %s, or use --os-cloud, --sel-credential-keyring or --sel-credential-command. Secrets aren't saved in config.json of the machine anymore"
+	errorKeyringNotSupported = "Keyring isn't supported on %s, use --sel-credential-command instead"
+	errorSecretWithNewline   = "Secrets with line breaks can't be stored in the keyring, use --sel-credential-command instead"
+	warningLegacySecret      = "Using the %s saved in config.json of machine '%s' by an older version of the driver, it can't be moved to the keyring: %s. Move it to one of the credential sources and remove it from the file"
+)
+
+const (
+	authMethodPassword              = "password"
+	authMethodApplicationCredential = "application-credential"
+	authMethodToken                 = "token"
+)
+
+// keyringService is the service name credentials are stored under in the OS keyring.
+const keyringService = "docker-machine-driver-selectel"
+
+// authMethod returns the auth method of the machine. Secrets aren't
+// persisted, so machines which haven't recorded the method have it
+// recognized by the given secrets and the persisted fields.
+func (d *Driver) authMethod() string {
+	switch {
+	case d.AuthMethod != "":
+		return d.AuthMethod
+	case d.Token != "":
+		return authMethodToken
+	case d.usesApplicationCredential() || d.LegacyApplicationCredentialSecret != "":
+		return authMethodApplicationCredential
+	case d.Password != "" || d.LegacyPassword != "" || d.Username != "":
+		return authMethodPassword
+	default:
+		return authMethodToken
+	}
+}
+
+// credential returns a pointer to the secret of the auth method together
+// with the secret saved by older versions of the driver, its description
+// and environment variable.
+func (d *Driver) credential() (secret *string, legacySecret, description, envVar string) {
+	switch d.authMethod() {
+	case authMethodApplicationCredential:
+		return &d.ApplicationCredentialSecret, d.LegacyApplicationCredentialSecret, "application credential secret", "OS_APPLICATION_CREDENTIAL_SECRET"
+	case authMethodPassword:
+		return &d.Password, d.LegacyPassword, "password", "OS_PASSWORD"
+	default:
+		return &d.Token, "", "token", "OS_TOKEN"
+	}
+}
+
+// credentialAccount identifies the credential in the keyring.
+func (d *Driver) credentialAccount() string {
+	identity := d.Username
+	if d.ApplicationCredentialID != "" || d.ApplicationCredentialName != "" {
+		identity = d.ApplicationCredentialID + d.ApplicationCredentialName
+	}
+	domainID, domainName := d.userDomain()
+	return fmt.Sprintf("%s %s%s/%s", d.AuthUrl, domainID, domainName, identity)
+}
+
+// resolveCredentials looks up the secret which isn't stored with the machine
+// in the environment, config.json of machines created by older versions,
+// clouds.yaml, the OS keyring and the credential command.
+func (d *Driver) resolveCredentials() error {
+	secret, legacySecret, description, envVar := d.credential()
+	if *secret != "" {
+		return nil
+	}
+
+	*secret = os.Getenv(envVar)
+	if *secret == "" && legacySecret != "" {
+		*secret = legacySecret
+		d.migrateLegacySecret(legacySecret, description)
+	}
+	if *secret == "" && d.Cloud != "" {
+		if err := d.loadCloudConfig(); err != nil {
+			return err
+		}
+	}
+	if *secret == "" && d.CredentialKeyring {
+		value, err := keyringLookup(d.credentialAccount())
+		if err != nil {
+			return err
+		}
+		*secret = value
+	}
+	if *secret == "" && d.CredentialCommand != "" {
+		value, err := runCredentialCommand(d.CredentialCommand)
+		if err != nil {
+			return err
+		}
+		*secret = value
+	}
+
+	if *secret == "" {
+		return fmt.Errorf(errorNoCredentials, description, d.MachineName, envVar)
+	}
+	return nil
+}
+
+// migrateLegacySecret moves the secret saved in config.json by older versions
+// of the driver to the keyring, so later commands look it up there. The
+// secret is removed from config.json when the machine is saved next time.
+func (d *Driver) migrateLegacySecret(legacySecret, description string) {
+	log.Infof("Moving the %s saved in config.json of machine '%s' to the keyring...", description, d.MachineName)
+	if err := keyringStore(d.credentialAccount(), legacySecret); err != nil {
+		log.Warnf(warningLegacySecret, description, d.MachineName, err)
+		return
+	}
+
+	d.CredentialKeyring = true
+	d.LegacyPassword = ""
+	d.LegacyApplicationCredentialSecret = ""
+}
+
+// storeCredential saves the secret to the OS keyring, so commands run later
+// for the machine find it there.
+func (d *Driver) storeCredential() error {
+	secret, _, _, _ := d.credential()
+	if !d.CredentialKeyring || *secret == "" {
+		return nil
+	}
+
+	log.Infof("Storing credentials in the keyring...")
+	return keyringStore(d.credentialAccount(), *secret)
+}
+
+func keyringLookup(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	default:
+		return "", fmt.Errorf(errorKeyringNotSupported, runtime.GOOS)
+	}
+
+	// a missing item is reported with a non-zero exit code
+	output, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+func keyringStore(account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", keyringService+" "+account,
+			"service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	case "darwin":
+		// the interactive mode reads the command from stdin, so the secret
+		// doesn't show up in the process list
+		if strings.ContainsAny(secret, "\r\n") {
+			return errors.New(errorSecretWithNewline)
+		}
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(keyringService), securityQuote(account), securityQuote(secret)))
+	default:
+		return fmt.Errorf(errorKeyringNotSupported, runtime.GOOS)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("can't store credentials in the keyring: %s: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// securityQuote quotes the argument of a command of the interactive mode
+// of the macOS security tool.
+func securityQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// runCredentialCommand runs the shell command which prints the secret.
+func runCredentialCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("credential command failed: %s", err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
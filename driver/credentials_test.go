@@ -0,0 +1,167 @@
+package driver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/machine/libmachine/state"
+)
+
+func TestAuthMethod(t *testing.T) {
+	tests := []struct {
+		driver   Driver
+		expected string
+	}{
+		{
+			driver:   Driver{Username: "user", Password: "secret"},
+			expected: authMethodPassword,
+		},
+		{
+			driver:   Driver{Username: "user", Token: "token"},
+			expected: authMethodToken,
+		},
+		{
+			driver:   Driver{Username: "user", ApplicationCredentialName: "credential"},
+			expected: authMethodApplicationCredential,
+		},
+		{
+			driver:   Driver{ApplicationCredentialID: "id"},
+			expected: authMethodApplicationCredential,
+		},
+		{
+			driver:   Driver{Username: "user"},
+			expected: authMethodPassword,
+		},
+		{
+			driver:   Driver{Username: "user", AuthMethod: authMethodToken},
+			expected: authMethodToken,
+		},
+		{
+			driver:   Driver{},
+			expected: authMethodToken,
+		},
+	}
+
+	for _, test := range tests {
+		if method := test.driver.authMethod(); method != test.expected {
+			t.Errorf("authMethod() of %+v = %q, expected %q", test.driver, method, test.expected)
+		}
+	}
+}
+
+func TestLegacyCredential(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected string
+	}{
+		{
+			config:   `{"Username": "user", "Password": "secret"}`,
+			expected: "secret",
+		},
+		{
+			config:   `{"ApplicationCredentialID": "id", "ApplicationCredentialSecret": "secret"}`,
+			expected: "secret",
+		},
+		{
+			config:   `{"Username": "user", "AuthMethod": "password"}`,
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		var d Driver
+		if err := json.Unmarshal([]byte(test.config), &d); err != nil {
+			t.Errorf("can't unmarshal %s: %s", test.config, err)
+			continue
+		}
+		secret, legacySecret, _, _ := d.credential()
+		if *secret != "" || legacySecret != test.expected {
+			t.Errorf("credential() of %s = %q, %q, expected no secret and %q", test.config, *secret, legacySecret, test.expected)
+		}
+	}
+}
+
+func TestSecurityQuote(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{`secret`, `"secret"`},
+		{`with space`, `"with space"`},
+		{`with "quotes"`, `"with \"quotes\""`},
+		{`back\slash`, `"back\\slash"`},
+		{``, `""`},
+	}
+
+	for _, test := range tests {
+		if result := securityQuote(test.value); result != test.expected {
+			t.Errorf("securityQuote(%q) = %s, expected %s", test.value, result, test.expected)
+		}
+	}
+}
+
+func TestMigrateLegacySecret(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the fake keyring is a secret-tool script")
+	}
+
+	dir, err := ioutil.TempDir("", "keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// secret-tool which keeps the stored secret in a file
+	stored := filepath.Join(dir, "stored")
+	script := "#!/bin/sh\ncat > " + stored + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer os.Setenv("OS_PASSWORD", os.Getenv("OS_PASSWORD"))
+	os.Unsetenv("OS_PASSWORD")
+
+	d := NewDriver("machine", dir)
+	d.Username = "user"
+	d.LegacyPassword = "secret"
+	if err := d.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials() returned error: %s", err)
+	}
+	if d.Password != "secret" {
+		t.Errorf("resolveCredentials() resolved password %q, expected %q", d.Password, "secret")
+	}
+	if d.LegacyPassword != "" || !d.CredentialKeyring {
+		t.Errorf("resolveCredentials() kept the legacy password %q, keyring: %t", d.LegacyPassword, d.CredentialKeyring)
+	}
+
+	data, err := ioutil.ReadFile(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "secret" {
+		t.Errorf("keyring stores %q, expected %q", data, "secret")
+	}
+}
+
+func TestAuthenticateWithoutCredentials(t *testing.T) {
+	defer os.Setenv("OS_PASSWORD", os.Getenv("OS_PASSWORD"))
+	os.Unsetenv("OS_PASSWORD")
+
+	d := NewDriver("machine", "")
+	d.Username = "user"
+	d.AuthMethod = authMethodPassword
+
+	if err := d.Stop(); err == nil {
+		t.Error("Stop() without a password returned no error")
+	}
+	if st, err := d.GetState(); err == nil {
+		t.Errorf("GetState() without a password = %s, expected error", st)
+	} else if st != state.None {
+		t.Errorf("GetState() without a password = %s, expected %s", st, state.None)
+	}
+}
@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+const (
+	errorRAMGranularity = "RAM must be a multiple of %d MB, got %d MB"
+	errorNegativeSize   = "%s can't be negative, got %d"
+)
+
+// customFlavorPrefix marks private flavors created by the driver. Such
+// flavors are shared by all machines of the project with the same resources.
+const customFlavorPrefix = "dm-"
+
+// customFlavorName returns a deterministic name of the private flavor
+// with the requested CPU, RAM and disk values.
+func (d *Driver) customFlavorName() string {
+	name := fmt.Sprintf("%s%dc-%dm-%dg", customFlavorPrefix, d.CPU, d.RAM, d.flavorDisk())
+	if d.FlavorSwap > 0 {
+		name += fmt.Sprintf("-%ds", d.FlavorSwap)
+	}
+	if d.FlavorEphemeral > 0 {
+		name += fmt.Sprintf("-%de", d.FlavorEphemeral)
+	}
+	if len(d.FlavorExtraSpecs) > 0 {
+		name += "-" + extraSpecsHash(d.FlavorExtraSpecs)
+	}
+	return name
+}
+
+// extraSpecsHash returns a short hash of extra specs which doesn't depend
+// on the order the specs were given in. Keys and values are quoted, so
+// separators inside them can't make different specs look the same.
+func extraSpecsHash(specs map[string]string) string {
+	pairs := make([]string, 0, len(specs))
+	for key, value := range specs {
+		pairs = append(pairs, fmt.Sprintf("%q=%q", key, value))
+	}
+	sort.Strings(pairs)
+
+	sum := sha1.Sum([]byte(strings.Join(pairs, ",")))
+	return fmt.Sprintf("%x", sum)[:8]
+}
+
+// checkFlavorConfig validates resources of a flavor the driver may create.
+func (d *Driver) checkFlavorConfig() error {
+	if d.FlavorID != "" || d.FlavorName != "" {
+		if len(d.FlavorExtraSpecs) > 0 {
+			return fmt.Errorf(errorExclusiveOptions, "Flavor name or id", "Flavor extra specs")
+		}
+		return nil
+	}
+
+	if d.RAM < 0 {
+		return fmt.Errorf(errorNegativeSize, "RAM", d.RAM)
+	}
+	if d.Disk < 0 {
+		return fmt.Errorf(errorNegativeSize, "Disk size", d.Disk)
+	}
+	if d.FlavorSwap < 0 {
+		return fmt.Errorf(errorNegativeSize, "Swap size", d.FlavorSwap)
+	}
+	if d.FlavorEphemeral < 0 {
+		return fmt.Errorf(errorNegativeSize, "Ephemeral disk size", d.FlavorEphemeral)
+	}
+	return nil
+}
+
+// fitsFlavor reports whether the flavor has enough resources for the machine.
+func (d *Driver) fitsFlavor(flavor flavors.Flavor) bool {
+	return flavor.VCPUs >= d.CPU && flavor.RAM >= d.RAM && flavor.Disk >= d.flavorDisk() &&
+		flavor.Swap >= d.FlavorSwap && flavor.Ephemeral >= d.FlavorEphemeral
+}
+
+// matchesFlavor reports whether the flavor has exactly the requested resources.
+func (d *Driver) matchesFlavor(flavor flavors.Flavor) bool {
+	return flavor.VCPUs == d.CPU && flavor.RAM == d.RAM && flavor.Disk == d.flavorDisk() &&
+		flavor.Swap == d.FlavorSwap && flavor.Ephemeral == d.FlavorEphemeral
+}
+
+// findFlavor returns the smallest existing flavor which fits the requested
+// CPU, RAM and disk values preferring exact matches, or nil if there is none.
+func (d *Driver) findFlavor() (*flavors.Flavor, error) {
+	allFlavors, err := d.client.ListFlavors()
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []flavors.Flavor
+	for _, flavor := range allFlavors {
+		if d.fitsFlavor(flavor) {
+			candidates = append(candidates, flavor)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		first, second := candidates[i], candidates[j]
+		if d.matchesFlavor(first) != d.matchesFlavor(second) {
+			return d.matchesFlavor(first)
+		}
+		if first.VCPUs != second.VCPUs {
+			return first.VCPUs < second.VCPUs
+		}
+		if first.RAM != second.RAM {
+			return first.RAM < second.RAM
+		}
+		return first.Disk < second.Disk
+	})
+
+	flavor := candidates[0]
+	if !d.matchesFlavor(flavor) {
+		log.Infof("No flavor matches CPU/RAM/disk values %d/%d/%d exactly", d.CPU, d.RAM, d.flavorDisk())
+	}
+	return &flavor, nil
+}
+
+// lookupFlavor returns the flavor with the given name or nil if there is none.
+func (d *Driver) lookupFlavor(name string) (*flavors.Flavor, error) {
+	allFlavors, err := d.client.ListFlavors()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, flavor := range allFlavors {
+		if flavor.Name == name {
+			return &flavor, nil
+		}
+	}
+	return nil, nil
+}
+
+// isCustomFlavor reports whether the flavor is a private flavor created
+// by the driver.
+func isCustomFlavor(flavor *flavors.Flavor) bool {
+	return !flavor.IsPublic && strings.HasPrefix(flavor.Name, customFlavorPrefix)
+}
+
+// customFlavor returns the private flavor with the requested resources
+// creating it if it doesn't exist yet.
+func (d *Driver) customFlavor() (*flavors.Flavor, error) {
+	name := d.customFlavorName()
+	flavor, err := d.lookupFlavor(name)
+	if err != nil || flavor != nil {
+		return flavor, err
+	}
+
+	if granularity := d.profile().ramGranularity; granularity > 0 {
+		if d.RAM < granularity || d.RAM%granularity != 0 {
+			return nil, fmt.Errorf(errorRAMGranularity, granularity, d.RAM)
+		}
+	}
+
+	log.Infof("Creating flavor with CPU/RAM/disk values %d/%d/%d and name %s", d.CPU, d.RAM, d.flavorDisk(), name)
+	opts := openstack.FlavorCreateOpts{
+		Name:       name,
+		VCPUs:      d.CPU,
+		RAM:        d.RAM,
+		Disk:       d.flavorDisk(),
+		Swap:       d.FlavorSwap,
+		Ephemeral:  d.FlavorEphemeral,
+		ExtraSpecs: d.FlavorExtraSpecs,
+	}
+	flavor, err = d.client.CreateFlavor(opts)
+	if err != nil {
+		// another machine may have created the same flavor in the meantime
+		if existing, lookupErr := d.lookupFlavor(name); lookupErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return flavor, nil
+}
+
+// removeCustomFlavor removes the private flavor created by the driver
+// unless it became public or servers other than the machine one still use it.
+func (d *Driver) removeCustomFlavor() error {
+	flavor, err := d.client.GetFlavorBy(nil, &d.FlavorID)
+	if err != nil {
+		return err
+	}
+	if flavor.IsPublic {
+		log.Infof("Flavor '%s' is public, keeping it", d.FlavorName)
+		return nil
+	}
+
+	allServers, err := d.client.ListServers(servers.ListOpts{Flavor: d.FlavorID})
+	if err != nil {
+		return err
+	}
+
+	for _, server := range allServers {
+		if server.ID != d.ServerID {
+			log.Infof("Flavor '%s' is still used by server '%s', keeping it", d.FlavorName, server.ID)
+			return nil
+		}
+	}
+
+	log.Infof("Removing flavor '%s'...", d.FlavorName)
+	return d.client.DeleteFlavor(d.FlavorID)
+}
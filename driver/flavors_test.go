@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+)
+
+func TestCustomFlavorName(t *testing.T) {
+	tests := []struct {
+		driver   Driver
+		expected string
+	}{
+		{
+			driver:   Driver{CPU: 2, RAM: 4096},
+			expected: "dm-2c-4096m-0g",
+		},
+		{
+			driver:   Driver{CPU: 1, RAM: 1024, Disk: 10},
+			expected: "dm-1c-1024m-10g",
+		},
+		{
+			driver:   Driver{CPU: 1, RAM: 1024, BootMode: bootModeLocal, VolumeSize: 20},
+			expected: "dm-1c-1024m-20g",
+		},
+		{
+			driver:   Driver{CPU: 1, RAM: 1024, Disk: 5, BootMode: bootModeLocal, VolumeSize: 20},
+			expected: "dm-1c-1024m-5g",
+		},
+		{
+			driver:   Driver{CPU: 4, RAM: 8192, FlavorSwap: 512, FlavorEphemeral: 30},
+			expected: "dm-4c-8192m-0g-512s-30e",
+		},
+		{
+			driver:   Driver{CPU: 2, RAM: 2048, FlavorExtraSpecs: map[string]string{"hw:cpu_policy": "dedicated"}},
+			expected: "dm-2c-2048m-0g-" + extraSpecsHash(map[string]string{"hw:cpu_policy": "dedicated"}),
+		},
+	}
+
+	for _, test := range tests {
+		result := test.driver.customFlavorName()
+		if result != test.expected {
+			t.Errorf("customFlavorName() = %q, expected %q", result, test.expected)
+		}
+	}
+}
+
+func TestExtraSpecsHash(t *testing.T) {
+	specs := map[string]string{"hw:cpu_policy": "dedicated", "hw:mem_page_size": "large"}
+
+	tests := []struct {
+		specs    map[string]string
+		expected bool
+	}{
+		{
+			specs:    map[string]string{"hw:mem_page_size": "large", "hw:cpu_policy": "dedicated"},
+			expected: true,
+		},
+		{
+			specs:    map[string]string{"hw:cpu_policy": "dedicated"},
+			expected: false,
+		},
+		{
+			specs:    map[string]string{"hw:cpu_policy": "shared", "hw:mem_page_size": "large"},
+			expected: false,
+		},
+		{
+			specs:    map[string]string{"hw:cpu_policy": "dedicated,hw:mem_page_size=large"},
+			expected: false,
+		},
+	}
+
+	hash := extraSpecsHash(specs)
+	if len(hash) != 8 {
+		t.Errorf("extraSpecsHash(%v) = %q, expected 8 characters", specs, hash)
+	}
+	for _, test := range tests {
+		result := extraSpecsHash(test.specs)
+		if (result == hash) != test.expected {
+			t.Errorf("extraSpecsHash(%v) = %q, extraSpecsHash(%v) = %q, expected equal: %t", test.specs, result, specs, hash, test.expected)
+		}
+	}
+}
+
+func TestIsCustomFlavor(t *testing.T) {
+	tests := []struct {
+		flavor   flavors.Flavor
+		expected bool
+	}{
+		{
+			flavor:   flavors.Flavor{Name: "dm-2c-4096m-0g"},
+			expected: true,
+		},
+		{
+			flavor:   flavors.Flavor{Name: "dm-2c-4096m-0g", IsPublic: true},
+			expected: false,
+		},
+		{
+			flavor:   flavors.Flavor{Name: "my-2c-4096m"},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		result := isCustomFlavor(&test.flavor)
+		if result != test.expected {
+			t.Errorf("isCustomFlavor(%q, public: %t) = %t, expected %t", test.flavor.Name, test.flavor.IsPublic, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/gophercloud/gophercloud"
+	"github.com/selectel/docker-machine-driver/resell"
+)
+
+const (
+	errorNoFreeFloatingIP   = "No free floating ip in project, allocate one before creating the machine"
+	errorNoResellFloatingIP = "No free floating ip in project, buy one in the panel or set the Resell API key (SEL_RESELL_API_KEY, --sel-resell-api-key) to buy it automatically"
+	errorNoResellAPIKey     = "Floating ip '%s' was bought for the machine and can't be released without the Resell API key, set SEL_RESELL_API_KEY or release it in the panel"
+)
+
+// resellAPIKey returns the key of the Resell API, which isn't stored
+// with the machine.
+func (d *Driver) resellAPIKey() string {
+	if d.ResellAPIKey != "" {
+		return d.ResellAPIKey
+	}
+	return os.Getenv("SEL_RESELL_API_KEY")
+}
+
+func (d *Driver) resellClient() (resell.Client, error) {
+	transport, err := d.transportOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	return resell.NewClient(resell.ClientOpts{
+		APIKey:    d.resellAPIKey(),
+		Endpoint:  d.ResellURL,
+		Transport: transport,
+	})
+}
+
+// requireFloatingIP makes sure the machine will get a floating ip, either
+// a free one of the project or one bought through the Resell API.
+func (d *Driver) requireFloatingIP() error {
+	fips, err := d.client.GetAllFloatingIP()
+	if err != nil {
+		return err
+	}
+
+	if len(fips) > 0 {
+		return nil
+	}
+	if !d.profile().resellAPI {
+		return errors.New(errorNoFreeFloatingIP)
+	}
+	if d.resellAPIKey() == "" {
+		return errors.New(errorNoResellFloatingIP)
+	}
+	return nil
+}
+
+// buyFloatingIPIfNeeded buys a floating ip in the region of the machine
+// if the project has no free one. The ip is reserved for the machine,
+// attached to it and released when the machine is removed.
+func (d *Driver) buyFloatingIPIfNeeded() error {
+	if !d.profile().resellAPI {
+		return nil
+	}
+
+	fips, err := d.client.GetAllFloatingIP()
+	if err != nil {
+		return err
+	}
+	if len(fips) > 0 {
+		return nil
+	}
+
+	client, err := d.resellClient()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("No free floating ip in project, buying one in region '%s'...", d.Region)
+	bought, err := client.CreateFloatingIPs(d.ProjectID, resell.FloatingIPCreateOpts{
+		Region:   d.Region,
+		Quantity: 1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(bought) < 1 {
+		return errors.New("Resell API returned no floating ip")
+	}
+	fip := bought[0]
+
+	log.Infof("Waiting for floating ip '%s' to show up in the project...", fip.FloatingIPAddress)
+	err = d.client.WaitForFloatingIP(fip.ID)
+	if err == nil {
+		// other machines created at the same time would take a free ip
+		err = d.client.ReserveFloatingIP(fip.ID, d.MachineName)
+	}
+	if err != nil {
+		// the machine isn't saved if the pre-create check fails
+		if releaseErr := client.DeleteFloatingIP(fip.ID); releaseErr != nil {
+			log.Errorf("Can't release floating ip '%s': %s", fip.FloatingIPAddress, releaseErr)
+		}
+		return fmt.Errorf("floating ip '%s' can't be reserved for the machine: %s", fip.FloatingIPAddress, err)
+	}
+
+	d.FloatingIPID = fip.ID
+	d.FloatingIPAddress = fip.FloatingIPAddress
+	d.FloatingIPOwned = true
+	return nil
+}
+
+// releaseFloatingIP releases the floating ip bought for the machine once
+// it's detached from the deleted server.
+func (d *Driver) releaseFloatingIP() error {
+	if d.resellAPIKey() == "" {
+		return fmt.Errorf(errorNoResellAPIKey, d.FloatingIPAddress)
+	}
+
+	err := d.client.WaitForFloatingIPStatus(d.FloatingIPID, "DOWN")
+	if _, ok := err.(gophercloud.ErrDefault404); ok {
+		log.Infof("Floating ip '%s' is already released", d.FloatingIPAddress)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := d.resellClient()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Releasing floating ip '%s'...", d.FloatingIPAddress)
+	if err := client.DeleteFloatingIP(d.FloatingIPID); err != nil && !resell.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
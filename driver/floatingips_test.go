@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+// floatingIPsClient returns the given free floating ips, other methods
+// of the client aren't implemented.
+type floatingIPsClient struct {
+	openstack.Client
+	fips []floatingips.FloatingIP
+}
+
+func (client *floatingIPsClient) GetAllFloatingIP() ([]floatingips.FloatingIP, error) {
+	return client.fips, nil
+}
+
+func TestRequireFloatingIP(t *testing.T) {
+	free := []floatingips.FloatingIP{{ID: "f1", FloatingIP: "203.0.113.1", Status: "DOWN"}}
+
+	tests := []struct {
+		profile      string
+		resellAPIKey string
+		fips         []floatingips.FloatingIP
+		expected     string
+	}{
+		{
+			profile:  profileSelectel,
+			fips:     free,
+			expected: "",
+		},
+		{
+			profile:  profileSelectel,
+			expected: errorNoResellFloatingIP,
+		},
+		{
+			profile:      profileSelectel,
+			resellAPIKey: "key",
+			expected:     "",
+		},
+		{
+			profile:  profileGeneric,
+			fips:     free,
+			expected: "",
+		},
+		{
+			profile:  profileGeneric,
+			expected: errorNoFreeFloatingIP,
+		},
+	}
+
+	for _, test := range tests {
+		d := &Driver{Profile: test.profile, ResellAPIKey: test.resellAPIKey, client: &floatingIPsClient{fips: test.fips}}
+		err := d.requireFloatingIP()
+
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		if message != test.expected {
+			t.Errorf("requireFloatingIP() with profile %s, key %q and %d free ips returned %q, expected %q",
+				test.profile, test.resellAPIKey, len(test.fips), message, test.expected)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/docker/machine/libmachine/log"
-	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumetypes"
 	"github.com/selectel/docker-machine-driver/openstack"
 )
 
@@ -15,20 +17,19 @@ const (
 	errorMandatoryEnvOrOption = "%s must be specified either using the environment variable %s or the CLI option %s"
 	errorMandatoryOption      = "%s must be specified using the CLI option %s"
 	errorExclusiveOptions     = "Either %s or %s must be specified, not both"
+	errorUnknownVolumeType    = "Volume type '%s' isn't available in zone '%s'. Available types: %s"
+	errorVolumeTypeZone       = "Volume type '%s' belongs to zone '%s', but the machine is in zone '%s'"
+	errorUnknownBootMode      = "Boot mode '%s' is unknown, use '%s' or '%s'"
+	errorFlavorWithoutDisk    = "Flavor '%s' has no local disk and can't be used with the local boot mode"
+	errorUnencryptedType      = "Volume type '%s' has no encryption spec, use an encrypted volume type with %s"
+	errorLocalDiskEncryption  = "Encryption of local disks can't be verified, use the '%s' boot mode with %s"
+	errorUnknownVisibility    = "Image visibility '%s' is unknown, use public, private, shared or community"
+	errorInvalidKeyValue      = "'%s' must be in the key=value format"
+	errorNoAuthMethod         = "Password (OS_PASSWORD, --os-password), application credential (OS_APPLICATION_CREDENTIAL_SECRET, --os-application-credential-secret) or token (OS_TOKEN, --os-token) must be specified"
+	errorManyAuthMethods      = "Only one of password, application credential or token must be specified"
+	errorIncompleteClientCert = "Client certificate (OS_CERT, --os-cert) and its key (OS_KEY, --os-key) must be specified together"
 )
 
-func requireFreeFloatingIP(client openstack.Client) error {
-	fips, err := client.GetAllFloatingIP()
-	if err != nil {
-		panic(err)
-	}
-
-	if len(fips) < 1 {
-		return errors.New("no free floating ip in project")
-	}
-	return nil
-}
-
 func createPublicKeyIfNeeded(client openstack.Client, keyName, keyPath string) error {
 	_, err := client.GetPublicKey(keyName)
 	// user has a ssh-key pair with given name
@@ -47,43 +48,143 @@ func createPublicKeyIfNeeded(client openstack.Client, keyName, keyPath string) e
 	return client.CreateKeyPair(keyName, string(publicKey))
 }
 
-func (d *Driver) checkConfig() error {
+// usesApplicationCredential reports whether the driver authenticates with
+// an application credential instead of the password.
+func (d *Driver) usesApplicationCredential() bool {
+	return d.ApplicationCredentialID != "" || d.ApplicationCredentialName != "" || d.ApplicationCredentialSecret != ""
+}
+
+func (d *Driver) checkAuthConfig() error {
+	if err := d.checkProfileConfig(); err != nil {
+		return err
+	}
 	if d.AuthUrl == "" {
 		return fmt.Errorf(errorMandatoryEnvOrOption, "Authentication URL", "OS_AUTH_URL", "--os-auth-url")
 	}
-	if d.DomainName == "" {
-		return fmt.Errorf(errorMandatoryEnvOrOption, "Domain name", "OS_PROJECT_DOMAIN_NAME", "--os-domain-name")
+	if (d.ClientCert == "") != (d.ClientKey == "") {
+		return errors.New(errorIncompleteClientCert)
+	}
+	if d.Proxy != "" {
+		if _, err := openstack.ParseProxyURL(d.Proxy); err != nil {
+			return err
+		}
+	}
+
+	methods := 0
+	for _, configured := range []bool{d.Password != "", d.usesApplicationCredential(), d.Token != ""} {
+		if configured {
+			methods++
+		}
+	}
+	if methods == 0 {
+		return errors.New(errorNoAuthMethod)
+	}
+	if methods > 1 {
+		return errors.New(errorManyAuthMethods)
+	}
+
+	if d.usesApplicationCredential() {
+		if err := d.checkApplicationCredentialConfig(); err != nil {
+			return err
+		}
+	} else if d.Token == "" && d.Username == "" {
+		return fmt.Errorf(errorMandatoryEnvOrOption, "Username", "OS_USERNAME", "--os-username")
+	}
+	return d.checkScopeConfig()
+}
+
+// checkApplicationCredentialConfig validates an application credential given
+// either by id or by name of the user. The credential defines the project.
+func (d *Driver) checkApplicationCredentialConfig() error {
+	if d.ApplicationCredentialSecret == "" {
+		return fmt.Errorf(errorMandatoryEnvOrOption, "Application credential secret", "OS_APPLICATION_CREDENTIAL_SECRET", "--os-application-credential-secret")
+	}
+	if d.ApplicationCredentialID != "" && d.ApplicationCredentialName != "" {
+		return fmt.Errorf(errorExclusiveOptions, "Application credential id", "Application credential name")
+	}
+	if d.ApplicationCredentialID == "" && d.ApplicationCredentialName == "" {
+		return fmt.Errorf(errorMandatoryEnvOrOption, "Application credential id", "OS_APPLICATION_CREDENTIAL_ID", "--os-application-credential-id")
 	}
-	if d.Username == "" {
+
+	// credentials are looked up by name among credentials of the user
+	if d.ApplicationCredentialName != "" && d.Username == "" {
 		return fmt.Errorf(errorMandatoryEnvOrOption, "Username", "OS_USERNAME", "--os-username")
 	}
-	if d.Password == "" {
-		return fmt.Errorf(errorMandatoryEnvOrOption, "Password", "OS_PASSWORD", "--os-password")
+	return nil
+}
+
+func (d *Driver) checkConfig() error {
+	if err := d.checkAuthConfig(); err != nil {
+		return err
+	}
+	if d.AvailabilityZonePolicy != zonePolicyFirst && d.AvailabilityZonePolicy != zonePolicyLeastUsed {
+		return fmt.Errorf(errorUnknownZonePolicy, d.AvailabilityZonePolicy, zonePolicyFirst, zonePolicyLeastUsed)
 	}
-	if d.ProjectID == "" {
-		return fmt.Errorf(errorMandatoryEnvOrOption, "Project id", "OS_PROJECT_ID", "--os-project-id")
+
+	if d.BootMode != bootModeVolume && d.BootMode != bootModeLocal {
+		return fmt.Errorf(errorUnknownBootMode, d.BootMode, bootModeVolume, bootModeLocal)
 	}
-	if d.AvailabilityZone == "" {
-		return fmt.Errorf(errorMandatoryEnvOrOption, "Availability Zone", "OS_AVAILABILITY_ZONE", "--os-availability-zone")
+
+	if d.RequireEncryption && d.BootMode == bootModeLocal {
+		return fmt.Errorf(errorLocalDiskEncryption, bootModeVolume, "--sel-require-encryption")
 	}
 
 	if d.FlavorName != "" && d.FlavorID != "" {
 		return fmt.Errorf(errorExclusiveOptions, "Flavor name", "Flavor id")
 	}
 
-	if d.ImageName == "" && d.ImageID == "" {
-		return fmt.Errorf(errorMandatoryOption, "Image name or Image id", "--os-image-name or --os-image-id")
+	if err := d.checkFlavorConfig(); err != nil {
+		return err
+	}
+
+	// profiles without a default image require one
+	if !d.hasImageOptions() {
+		return fmt.Errorf(errorMandatoryEnvOrOption, "Image name", "OS_IMAGE_NAME", "--os-image-name")
 	}
 	if d.ImageName != "" && d.ImageID != "" {
 		return fmt.Errorf(errorExclusiveOptions, "Image name", "Image id")
 	}
+	if d.hasImageFilters() && d.ImageID != "" {
+		return fmt.Errorf(errorExclusiveOptions, "Image filters", "Image id")
+	}
+	if d.ImageVisibility != "" && !isImageVisibility(d.ImageVisibility) {
+		return fmt.Errorf(errorUnknownVisibility, d.ImageVisibility)
+	}
+	if d.ImageFile != "" && (d.ImageID != "" || d.hasImageFilters()) {
+		return fmt.Errorf(errorExclusiveOptions, "Image file", "Image id or filters")
+	}
+	if err := d.checkImageConfig(); err != nil {
+		return err
+	}
 	if _, err := os.Stat(d.SSHKeyPath); err != nil {
 		return fmt.Errorf(errorMandatoryEnvOrOption, "KeyPairPath", "SEL_SSH_PRIVATE_KEY_PATH", "--sel-ssh-private-key-path")
 	}
 	return nil
 }
 
+// flavorDisk returns the local disk size for a new flavor. Servers which
+// boot from a volume don't need a local disk unless it's set explicitly.
+func (d *Driver) flavorDisk() int {
+	if d.Disk > 0 {
+		return d.Disk
+	}
+	if d.BootMode == bootModeLocal {
+		return d.VolumeSize
+	}
+	return 0
+}
+
 func (d *Driver) resolveNamesAndIds() error {
+	if d.ImageFile != "" {
+		if err := d.uploadImage(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.resolveImage(); err != nil {
+		return err
+	}
+
 	if d.FlavorID != "" {
 		log.Info("FlavorID was provided. Validating...")
 		if _, err := d.client.GetFlavorBy(nil, &d.FlavorID); err != nil {
@@ -105,29 +206,45 @@ func (d *Driver) resolveNamesAndIds() error {
 		log.Info("Got flavorID", d.FlavorID)
 	}
 
-	if d.FlavorID == "" && d.FlavorName == "" {
-		// todo: is RAM % 2 ?
-		d.FlavorName = mcnutils.GenerateRandomID()[0:31]
-		log.Info("No any information about flavor was provided.")
-		log.Infof("Creating flavor with CPU/RAM values %d/%d and name %s", d.CPU, d.RAM, d.FlavorName)
+	// extra specs of existing flavors are unknown, so only a custom flavor
+	// with the same specs may be reused
+	if d.FlavorID == "" && d.FlavorName == "" && len(d.FlavorExtraSpecs) == 0 {
+		log.Info("No any information about flavor was provided. Looking for a suitable one...")
+		flavor, err := d.findFlavor()
+		if err != nil {
+			return err
+		}
+
+		if flavor != nil {
+			d.FlavorID = flavor.ID
+			d.FlavorName = flavor.Name
+			// any machine using a flavor created by the driver may be
+			// the last one to remove it
+			d.CustomFlavor = isCustomFlavor(flavor)
+			log.Infof("Using flavor '%s' with CPU/RAM/disk values %d/%d/%d", flavor.Name, flavor.VCPUs, flavor.RAM, flavor.Disk)
+		}
+	}
 
-		flavor, err := d.client.CreateFlavor(d.FlavorName, d.CPU, d.RAM)
+	if d.FlavorID == "" && d.FlavorName == "" {
+		log.Info("No suitable flavor was found.")
+		flavor, err := d.customFlavor()
 		if err != nil {
 			return err
 		}
 
 		d.FlavorID = flavor.ID
+		d.FlavorName = flavor.Name
+		d.CustomFlavor = isCustomFlavor(flavor)
 	}
 
-	if d.ImageName != "" {
-		log.Infof("ImageName was provided. Getting ID bases on '%s'", d.ImageName)
-		image, err := d.client.GetImageBy(&d.ImageName, nil)
+	if d.BootMode == bootModeLocal {
+		flavor, err := d.client.GetFlavorBy(nil, &d.FlavorID)
 		if err != nil {
 			return err
 		}
-
-		d.ImageID = image.ID
-		log.Info("Got id", d.ImageID)
+		if flavor.Disk == 0 {
+			return fmt.Errorf(errorFlavorWithoutDisk, flavor.Name)
+		}
 	}
 
 	if d.NetworkID == "" {
@@ -149,3 +266,113 @@ func (d *Driver) resolveNamesAndIds() error {
 	}
 	return nil
 }
+
+func (d *Driver) resolveVolumeType() error {
+	volumeTypes, err := d.client.GetVolumeTypes()
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]volumetypes.VolumeType, len(volumeTypes))
+	for _, volumeType := range volumeTypes {
+		byName[volumeType.Name] = volumeType
+	}
+
+	// without a type the volume gets the default type of Cinder,
+	// which encryption can't be checked of
+	if d.VolumeType == "" {
+		if d.RequireEncryption {
+			return fmt.Errorf(errorMandatoryEnvOrOption, "Volume type", "SEL_VOLUME_TYPE", "--sel-volume-type")
+		}
+		log.Info("Using the default volume type")
+		return nil
+	}
+
+	// short names like "fast" are expanded to "fast.<availability zone>",
+	// full names must belong to the zone of the machine
+	candidates := []string{d.VolumeType}
+	if d.profile().zoneVolumeTypes {
+		zone := volumeTypeZone(d.VolumeType)
+		if zone == "" {
+			candidates = append(candidates, fmt.Sprintf(volumeTypeFormat, d.VolumeType, d.AvailabilityZone))
+		} else if d.AvailabilityZone != "" && zone != d.AvailabilityZone {
+			return fmt.Errorf(errorVolumeTypeZone, d.VolumeType, zone, d.AvailabilityZone)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if volumeType, ok := byName[candidate]; ok {
+			d.VolumeType = candidate
+			log.Infof("Using volume type '%s'", d.VolumeType)
+			return d.checkVolumeTypeEncryption(volumeType)
+		}
+	}
+	return fmt.Errorf(errorUnknownVolumeType, d.VolumeType, d.AvailabilityZone,
+		strings.Join(volumeTypesForZone(volumeTypes, d.AvailabilityZone), ", "))
+}
+
+// checkVolumeTypeEncryption makes sure the volume type has an encryption spec
+// when encryption is required. Reading the spec may be forbidden by the
+// Cinder policy, so it is done only if needed.
+func (d *Driver) checkVolumeTypeEncryption(volumeType volumetypes.VolumeType) error {
+	if !d.RequireEncryption {
+		return nil
+	}
+
+	encryption, err := d.client.GetVolumeTypeEncryption(volumeType.ID)
+	if err != nil {
+		return err
+	}
+	if encryption == nil {
+		return fmt.Errorf(errorUnencryptedType, volumeType.Name, "--sel-volume-type")
+	}
+
+	log.Infof("Volume type '%s' is encrypted with %s (%s)", volumeType.Name, encryption.Cipher, encryption.Provider)
+	return nil
+}
+
+// volumeTypesForZone returns sorted names of volume types which may be used
+// in the given availability zone. Types without zone suffix fit any zone.
+// volumeTypeZone returns the availability zone of a volume type named like
+// "fast.ru-1a" or an empty string for short names.
+func volumeTypeZone(name string) string {
+	index := strings.LastIndex(name, ".")
+	if index < 0 {
+		return ""
+	}
+	return name[index+1:]
+}
+
+func volumeTypesForZone(volumeTypes []volumetypes.VolumeType, zone string) []string {
+	var names []string
+	for _, volumeType := range volumeTypes {
+		if !strings.Contains(volumeType.Name, ".") || strings.HasSuffix(volumeType.Name, "."+zone) {
+			names = append(names, volumeType.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func isImageVisibility(visibility string) bool {
+	for _, known := range imageVisibilities {
+		if visibility == string(known) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseKeyValues parses options given in the key=value format.
+func parseKeyValues(values []string) (map[string]string, error) {
+	result := make(map[string]string, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf(errorInvalidKeyValue, value)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
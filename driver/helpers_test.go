@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumetypes"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	tests := []struct {
+		values   []string
+		expected map[string]string
+		isError  bool
+	}{
+		{
+			values:   nil,
+			expected: map[string]string{},
+		},
+		{
+			values:   []string{"os_distro=ubuntu", "os_version=16.04"},
+			expected: map[string]string{"os_distro": "ubuntu", "os_version": "16.04"},
+		},
+		{
+			values:   []string{"hw:cpu_policy=dedicated", "quota:disk_read_iops_sec="},
+			expected: map[string]string{"hw:cpu_policy": "dedicated", "quota:disk_read_iops_sec": ""},
+		},
+		{
+			values:   []string{"key=value=with=equals"},
+			expected: map[string]string{"key": "value=with=equals"},
+		},
+		{
+			values:   []string{"key=first", "key=second"},
+			expected: map[string]string{"key": "second"},
+		},
+		{
+			values:  []string{"novalue"},
+			isError: true,
+		},
+		{
+			values:  []string{"=value"},
+			isError: true,
+		},
+	}
+
+	for _, test := range tests {
+		result, err := parseKeyValues(test.values)
+		if test.isError {
+			if err == nil {
+				t.Errorf("parseKeyValues(%q) returned no error", test.values)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseKeyValues(%q) returned error: %s", test.values, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("parseKeyValues(%q) = %v, expected %v", test.values, result, test.expected)
+		}
+	}
+}
+
+// volumeTypesClient returns the given volume types, other methods
+// of the client aren't implemented.
+type volumeTypesClient struct {
+	openstack.Client
+	volumeTypes []volumetypes.VolumeType
+}
+
+func (client *volumeTypesClient) GetVolumeTypes() ([]volumetypes.VolumeType, error) {
+	return client.volumeTypes, nil
+}
+
+func TestResolveVolumeType(t *testing.T) {
+	client := &volumeTypesClient{
+		volumeTypes: []volumetypes.VolumeType{
+			{Name: "fast.ru-1a"},
+			{Name: "fast.ru-1b"},
+			{Name: "basic.ru-1a"},
+		},
+	}
+
+	tests := []struct {
+		volumeType string
+		zone       string
+		expected   string
+		isError    bool
+	}{
+		{
+			volumeType: "fast",
+			zone:       "ru-1b",
+			expected:   "fast.ru-1b",
+		},
+		{
+			volumeType: "fast.ru-1a",
+			zone:       "ru-1a",
+			expected:   "fast.ru-1a",
+		},
+		{
+			volumeType: "fast.ru-1a",
+			zone:       "ru-1b",
+			isError:    true,
+		},
+		{
+			volumeType: "basic",
+			zone:       "ru-1b",
+			isError:    true,
+		},
+	}
+
+	for _, test := range tests {
+		d := &Driver{VolumeType: test.volumeType, AvailabilityZone: test.zone, client: client}
+		err := d.resolveVolumeType()
+		if test.isError {
+			if err == nil {
+				t.Errorf("resolveVolumeType() of %q in zone %q returned no error", test.volumeType, test.zone)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveVolumeType() of %q in zone %q returned error: %s", test.volumeType, test.zone, err)
+			continue
+		}
+		if d.VolumeType != test.expected {
+			t.Errorf("resolveVolumeType() of %q in zone %q = %q, expected %q", test.volumeType, test.zone, d.VolumeType, test.expected)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package driver
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+const (
+	errorImageNotFound     = "No active image matches %s"
+	errorAmbiguousImage    = "Found %d active images matching %s, narrow the search, set --sel-image-sort or use --os-image-id:\n%s"
+	errorUnknownNameMatch  = "Image name match '%s' is unknown, use exact, glob or regex"
+	errorUnknownImageSort  = "Image sort rule '%s' is unknown, use created_at or version:<property>"
+	errorInvalidImageName  = "Image name '%s' is not a valid %s pattern: %s"
+	imageNameMatchExact    = "exact"
+	imageNameMatchGlob     = "glob"
+	imageNameMatchRegex    = "regex"
+	imageSortCreatedAt     = "created_at"
+	imageSortVersionPrefix = "version:"
+	errorUnknownDiskFormat = "Can't detect disk format of '%s', supported extensions: %s"
+	errorImageChecksum     = "Checksum of uploaded image '%s' is '%s', expected '%s'"
+)
+
+// distroSSHUsers maps os_distro values of cloud images to their default users.
+var distroSSHUsers = map[string]string{
+	"arch":    "arch",
+	"centos":  "centos",
+	"coreos":  "core",
+	"debian":  "debian",
+	"fedora":  "fedora",
+	"freebsd": "freebsd",
+	"rhel":    "cloud-user",
+	"ubuntu":  "ubuntu",
+}
+
+// diskFormats maps extensions of local image files to Glance disk formats.
+var diskFormats = map[string]string{
+	".qcow2": "qcow2",
+	".img":   "raw",
+	".raw":   "raw",
+	".vmdk":  "vmdk",
+	".vdi":   "vdi",
+	".vhd":   "vhd",
+	".iso":   "iso",
+}
+
+var imageVisibilities = []images.ImageVisibility{
+	images.ImageVisibilityPublic,
+	images.ImageVisibilityPrivate,
+	images.ImageVisibilityShared,
+	images.ImageVisibilityCommunity,
+}
+
+func (d *Driver) imageFilter() openstack.ImageFilter {
+	// patterns are matched locally
+	name := d.ImageName
+	if d.ImageNameMatch != imageNameMatchExact {
+		name = ""
+	}
+
+	return openstack.ImageFilter{
+		Name:       name,
+		Visibility: images.ImageVisibility(d.ImageVisibility),
+		Owner:      d.ImageOwner,
+		Tags:       d.ImageTags,
+		Properties: d.ImageProperties,
+	}
+}
+
+func (d *Driver) hasImageOptions() bool {
+	return d.ImageID != "" || d.ImageName != "" || d.ImageFile != "" || d.hasImageFilters()
+}
+
+func (d *Driver) hasImageFilters() bool {
+	return len(d.ImageProperties) > 0 || len(d.ImageTags) > 0 || d.ImageVisibility != "" || d.ImageOwner != ""
+}
+
+// describeImageFilter returns a human readable description of the image
+// conditions for error messages.
+func describeImageFilter(filter openstack.ImageFilter) string {
+	var conditions []string
+	if filter.Name != "" {
+		conditions = append(conditions, fmt.Sprintf("name '%s'", filter.Name))
+	}
+	if filter.Visibility != "" {
+		conditions = append(conditions, fmt.Sprintf("visibility '%s'", filter.Visibility))
+	}
+	if filter.Owner != "" {
+		conditions = append(conditions, fmt.Sprintf("owner '%s'", filter.Owner))
+	}
+	for _, tag := range filter.Tags {
+		conditions = append(conditions, fmt.Sprintf("tag '%s'", tag))
+	}
+	for key, value := range filter.Properties {
+		conditions = append(conditions, fmt.Sprintf("%s '%s'", key, value))
+	}
+	return strings.Join(conditions, ", ")
+}
+
+func (d *Driver) resolveImage() error {
+	if d.ImageID != "" {
+		log.Info("ImageID was provided. Validating...")
+		image, err := d.client.GetImage(d.ImageID)
+		if err != nil {
+			return err
+		}
+		if image.Status != images.ImageStatusActive {
+			return fmt.Errorf("image '%s' is not active, current status is '%s'", d.ImageID, image.Status)
+		}
+		return nil
+	}
+
+	filter := d.imageFilter()
+	description := describeImageFilter(filter)
+	if filter.Name != d.ImageName {
+		description = strings.TrimPrefix(fmt.Sprintf("%s, name %s '%s'", description, d.ImageNameMatch, d.ImageName), ", ")
+	}
+
+	log.Infof("Looking up image by %s...", description)
+	matches, err := d.client.ListImages(filter)
+	if err != nil {
+		return err
+	}
+
+	if matches, err = d.matchImageName(matches); err != nil {
+		return err
+	}
+	if len(matches) > 1 && d.ImageSort != "" {
+		sortImages(matches, d.ImageSort)
+		log.Infof("Found %d matching images, choosing the first one by '%s'", len(matches), d.ImageSort)
+		matches = matches[:1]
+	}
+
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf(errorImageNotFound, description)
+	case 1:
+		d.ImageID = matches[0].ID
+		log.Infof("Got id %s of image '%s'", d.ImageID, matches[0].Name)
+		return nil
+	}
+
+	var lines []string
+	for _, image := range matches {
+		lines = append(lines, fmt.Sprintf("  %s  %s  %s  created %s", image.ID, image.Name, image.Visibility, image.CreatedAt.Format("2006-01-02")))
+	}
+	return fmt.Errorf(errorAmbiguousImage, len(matches), description, strings.Join(lines, "\n"))
+}
+
+func (d *Driver) checkImageConfig() error {
+	switch d.ImageNameMatch {
+	case imageNameMatchExact:
+	case imageNameMatchGlob:
+		if _, err := path.Match(d.ImageName, ""); err != nil {
+			return fmt.Errorf(errorInvalidImageName, d.ImageName, d.ImageNameMatch, err)
+		}
+	case imageNameMatchRegex:
+		if _, err := d.imageNameRegexp(); err != nil {
+			return fmt.Errorf(errorInvalidImageName, d.ImageName, d.ImageNameMatch, err)
+		}
+	default:
+		return fmt.Errorf(errorUnknownNameMatch, d.ImageNameMatch)
+	}
+
+	if d.ImageSort != "" && d.ImageSort != imageSortCreatedAt && !strings.HasPrefix(d.ImageSort, imageSortVersionPrefix) {
+		return fmt.Errorf(errorUnknownImageSort, d.ImageSort)
+	}
+	return nil
+}
+
+// imageNameRegexp compiles the image name regex, which must match the whole
+// name like a glob does.
+func (d *Driver) imageNameRegexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + d.ImageName + ")$")
+}
+
+// matchImageName filters images by the name pattern.
+func (d *Driver) matchImageName(candidates []images.Image) ([]images.Image, error) {
+	if d.ImageNameMatch == imageNameMatchExact || d.ImageName == "" {
+		return candidates, nil
+	}
+
+	var pattern *regexp.Regexp
+	if d.ImageNameMatch == imageNameMatchRegex {
+		var err error
+		if pattern, err = d.imageNameRegexp(); err != nil {
+			return nil, err
+		}
+	}
+
+	var matches []images.Image
+	for _, image := range candidates {
+		matched := false
+		if pattern != nil {
+			matched = pattern.MatchString(image.Name)
+		} else {
+			var err error
+			if matched, err = path.Match(d.ImageName, image.Name); err != nil {
+				return nil, err
+			}
+		}
+		if matched {
+			matches = append(matches, image)
+		}
+	}
+	return matches, nil
+}
+
+// sortImages sorts images so the preferred one goes first: the newest one
+// or the one with the highest version in the given property.
+func sortImages(candidates []images.Image, rule string) {
+	if rule == imageSortCreatedAt {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].CreatedAt.After(candidates[j].CreatedAt)
+		})
+		return
+	}
+
+	property := strings.TrimPrefix(rule, imageSortVersionPrefix)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		first := imageProperty(candidates[i], property)
+		second := imageProperty(candidates[j], property)
+		return compareVersions(first, second) > 0
+	})
+}
+
+// imageProperty returns the image property as a string, images without the
+// property get an empty value which is lower than any version.
+func imageProperty(image images.Image, property string) string {
+	value, ok := image.Properties[property]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// compareVersions compares versions like "16.04" or "7.5.1804" part by part,
+// numeric parts are compared as numbers.
+func compareVersions(first, second string) int {
+	split := func(r rune) bool { return r == '.' || r == '-' || r == '_' }
+	firstParts := strings.FieldsFunc(first, split)
+	secondParts := strings.FieldsFunc(second, split)
+
+	for i := 0; i < len(firstParts) && i < len(secondParts); i++ {
+		firstNumber, firstErr := strconv.Atoi(firstParts[i])
+		secondNumber, secondErr := strconv.Atoi(secondParts[i])
+		switch {
+		case firstErr == nil && secondErr == nil && firstNumber != secondNumber:
+			if firstNumber > secondNumber {
+				return 1
+			}
+			return -1
+		case (firstErr != nil || secondErr != nil) && firstParts[i] != secondParts[i]:
+			return strings.Compare(firstParts[i], secondParts[i])
+		}
+	}
+	return len(firstParts) - len(secondParts)
+}
+
+func imageDiskFormat(file string) (string, error) {
+	format, ok := diskFormats[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		extensions := make([]string, 0, len(diskFormats))
+		for extension := range diskFormats {
+			extensions = append(extensions, extension)
+		}
+		sort.Strings(extensions)
+		return "", fmt.Errorf(errorUnknownDiskFormat, file, strings.Join(extensions, ", "))
+	}
+	return format, nil
+}
+
+func fileChecksum(file *os.File) (string, error) {
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uploadImage creates a private Glance image from the local image file,
+// or reuses an existing image with the same checksum if allowed.
+func (d *Driver) uploadImage() error {
+	file, err := os.Open(d.ImageFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Calculating checksum of '%s'...", d.ImageFile)
+	checksum, err := fileChecksum(file)
+	if err != nil {
+		return err
+	}
+
+	if d.ImageDeduplicate {
+		existing, err := d.client.ListImages(openstack.ImageFilter{})
+		if err != nil {
+			return err
+		}
+		for _, image := range existing {
+			if image.Checksum == checksum {
+				log.Infof("Image '%s' with the same checksum already exists, skipping upload", image.Name)
+				d.ImageID = image.ID
+				return nil
+			}
+		}
+	}
+
+	diskFormat, err := imageDiskFormat(d.ImageFile)
+	if err != nil {
+		return err
+	}
+
+	name := d.ImageName
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(d.ImageFile), filepath.Ext(d.ImageFile))
+	}
+	visibility := images.ImageVisibilityPrivate
+	opts := images.CreateOpts{
+		Name:            name,
+		Visibility:      &visibility,
+		ContainerFormat: "bare",
+		DiskFormat:      diskFormat,
+	}
+	image, err := d.client.CreateImage(opts)
+	if err != nil {
+		return err
+	}
+	// removed by PreCreateCheck if anything fails
+	d.uploadedImageID = image.ID
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	log.Infof("Uploading '%s' to image '%s' with id '%s'...", d.ImageFile, name, image.ID)
+	data := &progressReader{Reader: file, total: info.Size()}
+	if err := d.client.UploadImageData(image.ID, data); err != nil {
+		return err
+	}
+
+	log.Info("Waiting image ACTIVE status...")
+	if err := d.client.WaitForImageStatus(image.ID, images.ImageStatusActive); err != nil {
+		return err
+	}
+
+	uploaded, err := d.client.GetImage(image.ID)
+	if err != nil {
+		return err
+	}
+	if uploaded.Checksum != checksum {
+		return fmt.Errorf(errorImageChecksum, name, uploaded.Checksum, checksum)
+	}
+
+	d.ImageID = image.ID
+	return nil
+}
+
+func (d *Driver) deleteImage(imageID string) {
+	log.Infof("Removing image with id '%s'...", imageID)
+	if err := d.client.DeleteImage(imageID); err != nil {
+		log.Error(err)
+	}
+}
+
+// progressReader logs how much of the data is read every 10 percent.
+type progressReader struct {
+	io.Reader
+	total   int64
+	read    int64
+	percent int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+
+	if r.total > 0 {
+		if percent := r.read * 100 / r.total; percent/10 > r.percent/10 {
+			r.percent = percent
+			log.Infof("Uploaded %d%% (%d of %d MB)", percent, r.read>>20, r.total>>20)
+		}
+	}
+	return n, err
+}
+
+// resolveSSHUser picks the SSH user from the image metadata unless the user
+// is given explicitly: the os_admin_user property goes first, then the user
+// of the provider images and the default user of the os_distro. Only public
+// images are considered provider images, private and shared images are
+// usually uploaded cloud images with their own users.
+func (d *Driver) resolveSSHUser() error {
+	if d.SSHUser != "" {
+		return nil
+	}
+
+	image, err := d.client.GetImage(d.ImageID)
+	if err != nil {
+		return err
+	}
+
+	distro := imageProperty(*image, "os_distro")
+	if distro != "" {
+		log.Infof("Image '%s' contains %s %s", image.Name, distro, imageProperty(*image, "os_version"))
+	}
+
+	switch {
+	case imageProperty(*image, "os_admin_user") != "":
+		d.SSHUser = imageProperty(*image, "os_admin_user")
+	case d.profile().sshUser != "" && image.Visibility == images.ImageVisibilityPublic:
+		d.SSHUser = d.profile().sshUser
+	case distroSSHUsers[strings.ToLower(distro)] != "":
+		d.SSHUser = distroSSHUsers[strings.ToLower(distro)]
+	default:
+		d.SSHUser = defaultSSHUser
+	}
+	log.Infof("Using SSH user '%s'", d.SSHUser)
+	return nil
+}
@@ -0,0 +1,206 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		first    string
+		second   string
+		expected int
+	}{
+		{"16.04", "16.04", 0},
+		{"18.04", "16.04", 1},
+		{"16.04", "18.04", -1},
+		{"16.10", "16.04", 1},
+		{"7.5.1804", "7.10", -1},
+		{"7.5.1804", "7.5", 1},
+		{"7.5", "7.5.1804", -1},
+		{"1.2-beta", "1.2-alpha", 1},
+		{"2018_05", "2018.04", 1},
+		{"", "16.04", -1},
+		{"16.04", "", 1},
+		{"", "", 0},
+	}
+
+	for _, test := range tests {
+		result := compareVersions(test.first, test.second)
+		if sign(result) != test.expected {
+			t.Errorf("compareVersions(%q, %q) = %d, expected %d", test.first, test.second, result, test.expected)
+		}
+	}
+}
+
+func sign(value int) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
+
+func TestMatchImageName(t *testing.T) {
+	candidates := []images.Image{
+		{Name: "Ubuntu 16.04 LTS 64-bit"},
+		{Name: "Ubuntu 18.04 LTS 64-bit"},
+		{Name: "ubuntu-18.04"},
+		{Name: "my-ubuntu-18.04-old"},
+	}
+
+	tests := []struct {
+		name     string
+		match    string
+		expected []string
+	}{
+		{
+			name:     "Ubuntu 16.04 LTS 64-bit",
+			match:    imageNameMatchExact,
+			expected: []string{"Ubuntu 16.04 LTS 64-bit", "Ubuntu 18.04 LTS 64-bit", "ubuntu-18.04", "my-ubuntu-18.04-old"},
+		},
+		{
+			name:     "Ubuntu *",
+			match:    imageNameMatchGlob,
+			expected: []string{"Ubuntu 16.04 LTS 64-bit", "Ubuntu 18.04 LTS 64-bit"},
+		},
+		{
+			name:     "ubuntu-18*",
+			match:    imageNameMatchGlob,
+			expected: []string{"ubuntu-18.04"},
+		},
+		{
+			name:     "ubuntu-18",
+			match:    imageNameMatchRegex,
+			expected: nil,
+		},
+		{
+			name:     `ubuntu-18\.\d+`,
+			match:    imageNameMatchRegex,
+			expected: []string{"ubuntu-18.04"},
+		},
+		{
+			name:     ".*ubuntu-18.*",
+			match:    imageNameMatchRegex,
+			expected: []string{"ubuntu-18.04", "my-ubuntu-18.04-old"},
+		},
+		{
+			name:     "Ubuntu 16.04 LTS 64-bit|ubuntu-18.04",
+			match:    imageNameMatchRegex,
+			expected: []string{"Ubuntu 16.04 LTS 64-bit", "ubuntu-18.04"},
+		},
+	}
+
+	for _, test := range tests {
+		d := &Driver{ImageName: test.name, ImageNameMatch: test.match}
+		matches, err := d.matchImageName(candidates)
+		if err != nil {
+			t.Errorf("%s %q returned error: %s", test.match, test.name, err)
+			continue
+		}
+
+		var names []string
+		for _, image := range matches {
+			names = append(names, image.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s %q matched %q, expected %q", test.match, test.name, names, test.expected)
+		}
+	}
+}
+
+func TestSortImagesByVersion(t *testing.T) {
+	candidates := []images.Image{
+		{Name: "no version"},
+		{Name: "7.4", Properties: map[string]interface{}{"os_version": "7.4.1708"}},
+		{Name: "7.10", Properties: map[string]interface{}{"os_version": "7.10"}},
+		{Name: "7.5", Properties: map[string]interface{}{"os_version": "7.5.1804"}},
+	}
+
+	sortImages(candidates, imageSortVersionPrefix+"os_version")
+
+	var names []string
+	for _, image := range candidates {
+		names = append(names, image.Name)
+	}
+	expected := []string{"7.10", "7.5", "7.4", "no version"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("images are sorted as %q, expected %q", names, expected)
+	}
+}
+
+// imageClient returns the given image, other methods of the client
+// aren't implemented.
+type imageClient struct {
+	openstack.Client
+	image images.Image
+}
+
+func (client *imageClient) GetImage(imageID string) (*images.Image, error) {
+	return &client.image, nil
+}
+
+func TestResolveSSHUser(t *testing.T) {
+	tests := []struct {
+		profile  string
+		image    images.Image
+		expected string
+	}{
+		{
+			profile: profileSelectel,
+			image: images.Image{
+				Visibility: images.ImageVisibilityPublic,
+				Properties: map[string]interface{}{"os_distro": "ubuntu"},
+			},
+			expected: "root",
+		},
+		{
+			profile: profileSelectel,
+			image: images.Image{
+				Visibility: images.ImageVisibilityPrivate,
+				Properties: map[string]interface{}{"os_distro": "ubuntu"},
+			},
+			expected: "ubuntu",
+		},
+		{
+			profile: profileSelectel,
+			image: images.Image{
+				Visibility: images.ImageVisibilityShared,
+				Properties: map[string]interface{}{"os_distro": "centos", "os_admin_user": "admin"},
+			},
+			expected: "admin",
+		},
+		{
+			profile:  profileSelectel,
+			image:    images.Image{Visibility: images.ImageVisibilityPrivate},
+			expected: "root",
+		},
+		{
+			profile: profileGeneric,
+			image: images.Image{
+				Visibility: images.ImageVisibilityPublic,
+				Properties: map[string]interface{}{"os_distro": "debian"},
+			},
+			expected: "debian",
+		},
+	}
+
+	for _, test := range tests {
+		d := NewDriver("machine", "")
+		d.Profile = test.profile
+		d.client = &imageClient{image: test.image}
+		if err := d.resolveSSHUser(); err != nil {
+			t.Errorf("resolveSSHUser() of %s image %v returned error: %s", test.image.Visibility, test.image.Properties, err)
+			continue
+		}
+		if d.SSHUser != test.expected {
+			t.Errorf("resolveSSHUser() of %s image %v with %s profile = %q, expected %q",
+				test.image.Visibility, test.image.Properties, test.profile, d.SSHUser, test.expected)
+		}
+	}
+}
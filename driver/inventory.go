@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+const (
+	listFormatTable = "table"
+	listFormatJSON  = "json"
+)
+
+// inventory is a list of project resources. Rows are printed as a table
+// and items are printed as JSON.
+type inventory struct {
+	columns []string
+	rows    [][]string
+	items   interface{}
+}
+
+// inventories list resources which may be used as values of create flags.
+var inventories = map[string]func(client openstack.Client) (*inventory, error){
+	"flavors":      listFlavors,
+	"images":       listImages,
+	"networks":     listNetworks,
+	"volume-types": listVolumeTypes,
+	"zones":        listZones,
+}
+
+func inventoryNames() []string {
+	names := make([]string, 0, len(inventories))
+	for name := range inventories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (inv *inventory) print(w io.Writer, format string) error {
+	switch format {
+	case listFormatJSON:
+		data, err := json.MarshalIndent(inv.items, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case listFormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(inv.columns, "\t"))
+		for _, row := range inv.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("output format '%s' is unknown, use '%s' or '%s'", format, listFormatTable, listFormatJSON)
+}
+
+func listFlavors(client openstack.Client) (*inventory, error) {
+	allFlavors, err := client.ListFlavors()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(allFlavors, func(i, j int) bool {
+		return allFlavors[i].Name < allFlavors[j].Name
+	})
+
+	inv := &inventory{
+		columns: []string{"ID", "NAME", "VCPUS", "RAM", "DISK", "PUBLIC"},
+		items:   allFlavors,
+	}
+	for _, flavor := range allFlavors {
+		inv.rows = append(inv.rows, []string{flavor.ID, flavor.Name, strconv.Itoa(flavor.VCPUs),
+			strconv.Itoa(flavor.RAM), strconv.Itoa(flavor.Disk), strconv.FormatBool(flavor.IsPublic)})
+	}
+	return inv, nil
+}
+
+func listImages(client openstack.Client) (*inventory, error) {
+	allImages, err := client.ListImages(openstack.ImageFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(allImages, func(i, j int) bool {
+		return allImages[i].Name < allImages[j].Name
+	})
+
+	inv := &inventory{
+		columns: []string{"ID", "NAME", "VISIBILITY", "MIN DISK", "CREATED"},
+		items:   allImages,
+	}
+	for _, image := range allImages {
+		inv.rows = append(inv.rows, []string{image.ID, image.Name, string(image.Visibility),
+			strconv.Itoa(image.MinDiskGigabytes), image.CreatedAt.Format("2006-01-02")})
+	}
+	return inv, nil
+}
+
+func listNetworks(client openstack.Client) (*inventory, error) {
+	allNetworks, err := client.ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &inventory{
+		columns: []string{"ID", "NAME", "STATUS", "SHARED", "SUBNETS"},
+		items:   allNetworks,
+	}
+	for _, network := range allNetworks {
+		inv.rows = append(inv.rows, []string{network.ID, network.Name, network.Status,
+			strconv.FormatBool(network.Shared), strings.Join(network.Subnets, ",")})
+	}
+	return inv, nil
+}
+
+func listVolumeTypes(client openstack.Client) (*inventory, error) {
+	allTypes, err := client.GetVolumeTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(allTypes, func(i, j int) bool {
+		return allTypes[i].Name < allTypes[j].Name
+	})
+
+	inv := &inventory{
+		columns: []string{"ID", "NAME", "DESCRIPTION"},
+		items:   allTypes,
+	}
+	for _, volumeType := range allTypes {
+		inv.rows = append(inv.rows, []string{volumeType.ID, volumeType.Name, volumeType.Description})
+	}
+	return inv, nil
+}
+
+func listZones(client openstack.Client) (*inventory, error) {
+	zones, err := availabilityZones(client)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &inventory{
+		columns: []string{"NAME", "COMPUTE", "VOLUME"},
+		items:   zones,
+	}
+	for _, zone := range zones {
+		inv.rows = append(inv.rows, []string{zone.Name, strconv.FormatBool(zone.Compute), strconv.FormatBool(zone.Volume)})
+	}
+	return inv, nil
+}
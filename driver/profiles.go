@@ -0,0 +1,91 @@
+package driver
+
+import "fmt"
+
+const (
+	profileSelectel = "selectel"
+	profileGeneric  = "generic"
+	defaultProfile  = profileSelectel
+
+	errorUnknownProfile     = "Provider profile '%s' is unknown, use '%s' or '%s'"
+	errorResellNotSupported = "Resell API key can't be used with the '%s' provider profile, it's available only with the '%s' one"
+)
+
+// providerProfile holds the conventions of an OpenStack provider
+// the driver relies on.
+type providerProfile struct {
+	// volumeType is the default volume type, the default type of Cinder
+	// is used if it's empty
+	volumeType string
+
+	// zoneVolumeTypes enables expanding short volume type names like
+	// "fast" to "fast.<availability zone>"
+	zoneVolumeTypes bool
+
+	// image is the default image name, the image must be specified
+	// if it's empty
+	image string
+
+	// domainID is the domain of the user and the project if none
+	// is specified
+	domainID string
+
+	// sshUser is the user of the provider public images, it's preferred
+	// to the default user of the image distribution
+	sshUser string
+
+	// ramGranularity is the RAM step in MB accepted for custom flavors,
+	// any RAM is accepted if it's zero
+	ramGranularity int
+
+	// resellAPI enables buying floating IPs through the Selectel
+	// VPC Resell API
+	resellAPI bool
+
+	// serverPasswordHash sets the x_sel_server_password_hash metadata
+	// Selectel expects on every server
+	serverPasswordHash bool
+}
+
+var profiles = map[string]*providerProfile{
+	profileSelectel: {
+		volumeType:         defaultVolumeType,
+		zoneVolumeTypes:    true,
+		image:              defaultImage,
+		sshUser:            defaultSSHUser,
+		ramGranularity:     512,
+		resellAPI:          true,
+		serverPasswordHash: true,
+	},
+	profileGeneric: {
+		domainID: "default",
+	},
+}
+
+// profile returns the provider profile of the driver. Machines created
+// before profiles were introduced have the Selectel one.
+func (d *Driver) profile() *providerProfile {
+	if profile, ok := profiles[d.Profile]; ok {
+		return profile
+	}
+	return profiles[defaultProfile]
+}
+
+func (d *Driver) checkProfileConfig() error {
+	if _, ok := profiles[d.Profile]; !ok {
+		return fmt.Errorf(errorUnknownProfile, d.Profile, profileSelectel, profileGeneric)
+	}
+	if d.ResellAPIKey != "" && !d.profile().resellAPI {
+		return fmt.Errorf(errorResellNotSupported, d.Profile, profileSelectel)
+	}
+	return nil
+}
+
+// serverMetadata returns the metadata the profile sets on the server.
+func (d *Driver) serverMetadata() map[string]string {
+	metadata := make(map[string]string)
+	if d.profile().serverPasswordHash {
+		metadata["x_sel_server_password_hash"] = fmt.Sprintf("$6$%s", "server_password_hash")
+	}
+	return metadata
+}
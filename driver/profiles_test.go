@@ -0,0 +1,33 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServerMetadata(t *testing.T) {
+	tests := []struct {
+		profile  string
+		expected map[string]string
+	}{
+		{
+			profile:  profileSelectel,
+			expected: map[string]string{"x_sel_server_password_hash": "$6$server_password_hash"},
+		},
+		{
+			profile:  "",
+			expected: map[string]string{"x_sel_server_password_hash": "$6$server_password_hash"},
+		},
+		{
+			profile:  profileGeneric,
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		d := &Driver{Profile: test.profile}
+		if metadata := d.serverMetadata(); !reflect.DeepEqual(metadata, test.expected) {
+			t.Errorf("serverMetadata() of profile %q = %v, expected %v", test.profile, metadata, test.expected)
+		}
+	}
+}
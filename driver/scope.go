@@ -0,0 +1,147 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+)
+
+const (
+	errorNoProject             = "Project must be specified either by id (OS_PROJECT_ID, --os-project-id) or by name (OS_PROJECT_NAME, --os-project-name)"
+	errorUserDomainRequired    = "Domain of user '%s' must be specified using OS_USER_DOMAIN_NAME (--os-user-domain-name) or OS_USER_DOMAIN_ID (--os-user-domain-id)"
+	errorProjectDomainRequired = "Domain of project '%s' must be specified using OS_PROJECT_DOMAIN_NAME (--os-project-domain-name) or OS_PROJECT_DOMAIN_ID (--os-project-domain-id)"
+	errorWrongProject          = "Token is scoped to project '%s' (%s) instead of '%s'"
+	errorAuthFailed            = "Authentication as %s failed, check the credentials, the domains and the project: %s"
+)
+
+// userDomain returns the domain id or name the user belongs to.
+// The domain name given by --os-domain-name is used if the user domain
+// isn't specified, and then the domain of the provider profile.
+func (d *Driver) userDomain() (id, name string) {
+	if d.UserDomainID != "" {
+		return d.UserDomainID, ""
+	}
+	if d.UserDomainName != "" {
+		return "", d.UserDomainName
+	}
+	if d.DomainName != "" {
+		return "", d.DomainName
+	}
+	return d.profile().domainID, ""
+}
+
+// projectDomain returns the domain id or name the project belongs to.
+func (d *Driver) projectDomain() (id, name string) {
+	if d.ProjectDomainID != "" {
+		return d.ProjectDomainID, ""
+	}
+	if d.ProjectDomainName != "" {
+		return "", d.ProjectDomainName
+	}
+	if d.DomainName != "" {
+		return "", d.DomainName
+	}
+	return d.profile().domainID, ""
+}
+
+// projectScope returns the Keystone scope of the token. The project id is
+// unique, so its domain is needed only to find the project by name.
+func (d *Driver) projectScope() *gophercloud.AuthScope {
+	if d.ProjectID != "" {
+		return &gophercloud.AuthScope{ProjectID: d.ProjectID}
+	}
+	if d.ProjectName != "" {
+		domainID, domainName := d.projectDomain()
+		return &gophercloud.AuthScope{
+			ProjectName: d.ProjectName,
+			DomainID:    domainID,
+			DomainName:  domainName,
+		}
+	}
+	return nil
+}
+
+// checkScopeConfig validates the domains of the user and the project.
+// Application credentials are bound to their project.
+func (d *Driver) checkScopeConfig() error {
+	if d.UserDomainID != "" && d.UserDomainName != "" {
+		return fmt.Errorf(errorExclusiveOptions, "User domain id", "User domain name")
+	}
+	if d.ProjectDomainID != "" && d.ProjectDomainName != "" {
+		return fmt.Errorf(errorExclusiveOptions, "Project domain id", "Project domain name")
+	}
+
+	// the user is looked up by name for the password and credentials by name
+	if d.Password != "" || d.ApplicationCredentialName != "" {
+		if id, name := d.userDomain(); id == "" && name == "" {
+			return fmt.Errorf(errorUserDomainRequired, d.Username)
+		}
+	}
+
+	if d.usesApplicationCredential() {
+		return nil
+	}
+	if d.ProjectID != "" && d.ProjectName != "" {
+		return fmt.Errorf(errorExclusiveOptions, "Project id", "Project name")
+	}
+	if d.ProjectID == "" && d.ProjectName == "" {
+		return errors.New(errorNoProject)
+	}
+	if d.ProjectName != "" {
+		if id, name := d.projectDomain(); id == "" && name == "" {
+			return fmt.Errorf(errorProjectDomainRequired, d.ProjectName)
+		}
+	}
+	return nil
+}
+
+// checkProjectScope makes sure the token is scoped to the requested project
+// and records the project id, as the project may be given by name or
+// defined by the application credential.
+func (d *Driver) checkProjectScope(project *tokens.Project) error {
+	if d.ProjectID != "" && project.ID != d.ProjectID {
+		return fmt.Errorf(errorWrongProject, project.Name, project.ID, d.ProjectID)
+	}
+	if d.ProjectName != "" && project.Name != d.ProjectName {
+		return fmt.Errorf(errorWrongProject, project.Name, project.ID, d.ProjectName)
+	}
+
+	d.ProjectID = project.ID
+	return nil
+}
+
+// describeScope describes who authenticates and for which project,
+// to explain a rejected authentication.
+func (d *Driver) describeScope() string {
+	quoteDomain := func(id, name string) string {
+		if id != "" {
+			return fmt.Sprintf("id '%s'", id)
+		}
+		return fmt.Sprintf("'%s'", name)
+	}
+
+	var parts []string
+	switch {
+	case d.ApplicationCredentialID != "":
+		parts = append(parts, fmt.Sprintf("application credential '%s'", d.ApplicationCredentialID))
+	case d.ApplicationCredentialName != "":
+		parts = append(parts, fmt.Sprintf("application credential '%s' of", d.ApplicationCredentialName))
+	case d.Token != "":
+		parts = append(parts, "token")
+	}
+	if d.Password != "" || d.ApplicationCredentialName != "" {
+		parts = append(parts, fmt.Sprintf("user '%s' of domain %s", d.Username, quoteDomain(d.userDomain())))
+	}
+
+	if !d.usesApplicationCredential() {
+		if d.ProjectID != "" {
+			parts = append(parts, fmt.Sprintf("in project '%s'", d.ProjectID))
+		} else if d.ProjectName != "" {
+			parts = append(parts, fmt.Sprintf("in project '%s' of domain %s", d.ProjectName, quoteDomain(d.projectDomain())))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -4,8 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"net/url"
-	"os"
 	"os/user"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -18,6 +16,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/selectel/docker-machine-driver/openstack"
+	"github.com/selectel/docker-machine-driver/resell"
 )
 
 const (
@@ -28,43 +27,95 @@ const (
 
 	// volume
 	defaultVolumeName = "volume for %s"
-	defaultVolumeType = "fast.%s"
+	defaultVolumeType = "fast"
+	volumeTypeFormat  = "%s.%s"
 	defaultVolumeSize = 5
 
 	// flavor
 	defaultCPUValue = 1
 	defaultRAMValue = 512
 
-	// other
-	defaultImage = "Ubuntu 16.04 LTS 64-bit"
+	// boot
+	bootModeVolume  = "volume"
+	bootModeLocal   = "local"
+	defaultBootMode = bootModeVolume
+
+	// image
+	defaultImage          = "Ubuntu 16.04 LTS 64-bit"
+	defaultImageNameMatch = imageNameMatchExact
 )
 
 type Driver struct {
 	*drivers.BaseDriver
-	client           openstack.Client
-	AuthUrl          string
-	DomainName       string
-	Username         string
-	Password         string
-	ProjectID        string
-	Region           string
-	AvailabilityZone string
-	ServerID         string
-	VolumeID         string
-	Proxy            string
-	RAM              int
-	CPU              int
-	SSHKeyName       string
-	SSHPublicKeyPath string
-	ServerName       string
-	VolumeName       string
-	VolumeSize       int
-	VolumeType       string
-	FlavorName       string
-	FlavorID         string
-	ImageName        string
-	ImageID          string
-	NetworkID        string
+	client                      openstack.Client
+	uploadedImageID             string
+	Profile                     string
+	Cloud                       string
+	AuthUrl                     string
+	DomainName                  string
+	UserDomainName              string
+	UserDomainID                string
+	Username                    string
+	Password                    string `json:"-"`
+	ApplicationCredentialID     string
+	ApplicationCredentialName   string
+	ApplicationCredentialSecret string `json:"-"`
+	Token                       string `json:"-"`
+	AuthMethod                  string
+	TokenCache                  bool
+	CredentialKeyring           bool
+	CredentialCommand           string
+	ProjectID                   string
+	ProjectName                 string
+	ProjectDomainName           string
+	ProjectDomainID             string
+	Region                      string
+	AvailabilityZone            string
+	AvailabilityZonePolicy      string
+	ServerID                    string
+	VolumeID                    string
+	Proxy                       string
+	ResellAPIKey                string `json:"-"`
+	ResellURL                   string
+	FloatingIPID                string
+	FloatingIPAddress           string
+	FloatingIPOwned             bool
+	CACert                      string
+	ClientCert                  string
+	ClientKey                   string
+	Insecure                    bool
+	RAM                         int
+	CPU                         int
+	SSHKeyName                  string
+	SSHPublicKeyPath            string
+	ServerName                  string
+	VolumeName                  string
+	VolumeSize                  int
+	VolumeType                  string
+	FlavorName                  string
+	FlavorID                    string
+	CustomFlavor                bool
+	Disk                        int
+	FlavorSwap                  int
+	FlavorEphemeral             int
+	FlavorExtraSpecs            map[string]string
+	ImageName                   string
+	ImageID                     string
+	ImageProperties             map[string]string
+	ImageTags                   []string
+	ImageVisibility             string
+	ImageOwner                  string
+	ImageNameMatch              string
+	ImageSort                   string
+	ImageFile                   string
+	ImageDeduplicate            bool
+	NetworkID                   string
+	BootMode                    string
+	RequireEncryption           bool
+
+	// secrets saved in config.json by older versions of the driver
+	LegacyPassword                    string `json:"Password,omitempty"`
+	LegacyApplicationCredentialSecret string `json:"ApplicationCredentialSecret,omitempty"`
 }
 
 func NewDriver(hostName string, storePath string) *Driver {
@@ -79,6 +130,18 @@ func NewDriver(hostName string, storePath string) *Driver {
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	return []mcnflag.Flag{
 		// openstack variables
+		mcnflag.StringFlag{
+			EnvVar: "SEL_PROFILE",
+			Name:   "sel-profile",
+			Usage:  "Provider profile: selectel or generic for other OpenStack clouds",
+			Value:  defaultProfile,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_CLOUD",
+			Name:   "os-cloud",
+			Usage:  "Name of the cloud in clouds.yaml to take settings not given explicitly from",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_AUTH_URL",
 			Name:   "os-auth-url",
@@ -94,13 +157,31 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "OS_AVAILABILITY_ZONE",
 			Name:   "os-availability-zone",
-			Usage:  "OpenStack availability zone",
+			Usage:  "OpenStack availability zone, picked automatically if not set",
 			Value:  "",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "OS_PROJECT_DOMAIN_NAME",
+			EnvVar: "SEL_AVAILABILITY_ZONE_POLICY",
+			Name:   "sel-availability-zone-policy",
+			Usage:  "How to pick the availability zone if none is set: first or least-used",
+			Value:  defaultZonePolicy,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_DEFAULT_DOMAIN_NAME",
 			Name:   "os-domain-name",
-			Usage:  "OpenStack domain name (identity v3 only)",
+			Usage:  "OpenStack domain name of the user and the project if they aren't specified separately",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_USER_DOMAIN_NAME",
+			Name:   "os-user-domain-name",
+			Usage:  "OpenStack domain name of the user",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_USER_DOMAIN_ID",
+			Name:   "os-user-domain-id",
+			Usage:  "OpenStack domain id of the user",
 			Value:  "",
 		},
 		mcnflag.StringFlag{
@@ -115,12 +196,70 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack user password",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_APPLICATION_CREDENTIAL_ID",
+			Name:   "os-application-credential-id",
+			Usage:  "OpenStack application credential id, used instead of the password",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_APPLICATION_CREDENTIAL_NAME",
+			Name:   "os-application-credential-name",
+			Usage:  "OpenStack application credential name of the user, used instead of the password",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_APPLICATION_CREDENTIAL_SECRET",
+			Name:   "os-application-credential-secret",
+			Usage:  "OpenStack application credential secret",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_TOKEN",
+			Name:   "os-token",
+			Usage:  "Pre-issued OpenStack token, used instead of the password",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "SEL_TOKEN_CACHE",
+			Name:   "sel-token-cache",
+			Usage:  "Cache the OpenStack token encrypted in the machine storage",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "SEL_CREDENTIAL_KEYRING",
+			Name:   "sel-credential-keyring",
+			Usage:  "Store the password or secret in the OS keyring and look it up there",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_CREDENTIAL_COMMAND",
+			Name:   "sel-credential-command",
+			Usage:  "Shell command printing the password or secret, it isn't stored with the machine",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_PROJECT_ID",
 			Name:   "os-project-id",
 			Usage:  "OpenStack project id",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_PROJECT_NAME",
+			Name:   "os-project-name",
+			Usage:  "OpenStack project name, used with the project domain instead of the project id",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_PROJECT_DOMAIN_NAME",
+			Name:   "os-project-domain-name",
+			Usage:  "OpenStack domain name of the project",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_PROJECT_DOMAIN_ID",
+			Name:   "os-project-domain-id",
+			Usage:  "OpenStack domain id of the project",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_FLAVOR_ID",
 			Name:   "os-flavor-id",
@@ -135,8 +274,8 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "OS_IMAGE_NAME",
 			Name:   "os-image-name",
-			Usage:  "OpenStack flavor name to use for the instance",
-			Value:  defaultImage,
+			Usage:  "OpenStack image name to use for the instance, \"Ubuntu 16.04 LTS 64-bit\" with the selectel profile if no other image options are given",
+			Value:  "",
 		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_IMAGE_ID",
@@ -144,6 +283,47 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "OpenStack image id to use for the instance",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_IMAGE_NAME_MATCH",
+			Name:   "sel-image-name-match",
+			Usage:  "How the image name is matched: exact, glob or regex, patterns match the whole name",
+			Value:  defaultImageNameMatch,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_IMAGE_SORT",
+			Name:   "sel-image-sort",
+			Usage:  "Choose the first of several matching images by created_at (newest) or version:<property> (highest)",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "SEL_IMAGE_PROPERTY",
+			Name:   "sel-image-property",
+			Usage:  "Image property to look up the image by, e.g. os_distro=ubuntu (may be repeated)",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "SEL_IMAGE_TAG",
+			Name:   "sel-image-tag",
+			Usage:  "Image tag to look up the image by (may be repeated)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_IMAGE_VISIBILITY",
+			Name:   "sel-image-visibility",
+			Usage:  "Image visibility to look up the image by: public, private, shared or community",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_IMAGE_OWNER",
+			Name:   "sel-image-owner",
+			Usage:  "Project id of the image owner to look up the image by",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_IMAGE_FILE",
+			Name:   "sel-image-file",
+			Usage:  "Local disk image (qcow2, raw, vmdk, vdi, vhd or iso) to upload and use for the instance",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "SEL_IMAGE_DEDUP",
+			Name:   "sel-image-dedup",
+			Usage:  "Use an existing image with the same checksum instead of uploading the image file",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_NETWORK_NAME",
 			Name:   "os-net-name",
@@ -161,8 +341,7 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "SEL_SSH_USER",
 			Name:   "sel-ssh-user",
-			Usage:  "SSH user for connecting to the server",
-			Value:  defaultSSHUser,
+			Usage:  "SSH user for connecting to the server, detected from the image metadata if not set",
 		},
 		mcnflag.IntFlag{
 			EnvVar: "SEL_SSH_PORT",
@@ -191,15 +370,30 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "SEL_VOLUME_TYPE",
 			Name:   "sel-volume-type",
-			Usage:  "Base volume type for server",
+			Usage:  "Volume type for server, short names (fast, universal, basic) are expanded for the availability zone with the selectel profile",
+			Value:  "",
 		},
 		mcnflag.IntFlag{
 			EnvVar: "SEL_VOLUME_SIZE",
 			Name:   "sel-volume-size",
-			Usage:  "Volume size",
+			Usage:  "Volume size, or flavor local disk size for the local boot mode",
 			Value:  defaultVolumeSize,
 		},
 
+		mcnflag.BoolFlag{
+			EnvVar: "SEL_REQUIRE_ENCRYPTION",
+			Name:   "sel-require-encryption",
+			Usage:  "Refuse to create volumes with a volume type without encryption",
+		},
+
+		// boot variables
+		mcnflag.StringFlag{
+			EnvVar: "SEL_BOOT_MODE",
+			Name:   "sel-boot-mode",
+			Usage:  "Boot from a network volume (volume) or from the image on a flavor local disk (local)",
+			Value:  defaultBootMode,
+		},
+
 		// other variables
 		mcnflag.StringFlag{
 			EnvVar: "SEL_SERVER_NAME",
@@ -209,7 +403,62 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "SEL_PROXY",
 			Name:   "sel-proxy",
-			Usage:  "Proxy for the OS services",
+			Usage:  "Proxy URL for the OS services: http, https or socks5, HTTPS_PROXY is used if not set",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_RESELL_API_KEY",
+			Name:   "sel-resell-api-key",
+			Usage:  "Selectel Resell API key to buy a floating ip if the project has no free one, it isn't stored with the machine",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "SEL_RESELL_URL",
+			Name:   "sel-resell-url",
+			Usage:  "Selectel Resell API URL",
+			Value:  resell.DefaultEndpoint,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_CACERT",
+			Name:   "os-cacert",
+			Usage:  "PEM bundle of CAs to verify OpenStack endpoints with instead of the system ones",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_CERT",
+			Name:   "os-cert",
+			Usage:  "PEM client certificate for OpenStack endpoints requiring mutual TLS",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "OS_KEY",
+			Name:   "os-key",
+			Usage:  "PEM key of the client certificate",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "OS_INSECURE",
+			Name:   "os-insecure",
+			Usage:  "Don't verify TLS certificates of OpenStack endpoints, for testing only",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SEL_DISK",
+			Name:   "sel-disk",
+			Usage:  "Local disk size in GB of a custom flavor, defaults to the volume size for the local boot mode",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SEL_FLAVOR_SWAP",
+			Name:   "sel-flavor-swap",
+			Usage:  "Swap size in MB of a custom flavor",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "SEL_FLAVOR_EPHEMERAL",
+			Name:   "sel-flavor-ephemeral",
+			Usage:  "Ephemeral disk size in GB of a custom flavor",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "SEL_FLAVOR_EXTRA_SPEC",
+			Name:   "sel-flavor-extra-spec",
+			Usage:  "Extra spec of a custom flavor in the key=value format",
 		},
 		mcnflag.IntFlag{
 			EnvVar: "SEL_CPU_VALUE",
@@ -226,6 +475,76 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	}
 }
 
+// authFlagNames are the create flags needed to authenticate, they are
+// also accepted by driver commands which don't operate on stored machines.
+var authFlagNames = []string{
+	"sel-profile",
+	"os-cloud",
+	"os-auth-url",
+	"os-username",
+	"os-password",
+	"os-application-credential-id",
+	"os-application-credential-name",
+	"os-application-credential-secret",
+	"os-token",
+	"sel-token-cache",
+	"sel-credential-keyring",
+	"sel-credential-command",
+	"os-domain-name",
+	"os-user-domain-name",
+	"os-user-domain-id",
+	"os-region",
+	"os-project-id",
+	"os-project-name",
+	"os-project-domain-name",
+	"os-project-domain-id",
+	"sel-proxy",
+	"os-cacert",
+	"os-cert",
+	"os-key",
+	"os-insecure",
+}
+
+func (d *Driver) setAuthConfigFromFlags(opts drivers.DriverOptions) error {
+	d.Profile = opts.String("sel-profile")
+	d.Cloud = opts.String("os-cloud")
+	d.AuthUrl = opts.String("os-auth-url")
+	d.Username = opts.String("os-username")
+	d.Password = opts.String("os-password")
+	d.ApplicationCredentialID = opts.String("os-application-credential-id")
+	d.ApplicationCredentialName = opts.String("os-application-credential-name")
+	d.ApplicationCredentialSecret = opts.String("os-application-credential-secret")
+	d.Token = opts.String("os-token")
+	d.TokenCache = opts.Bool("sel-token-cache")
+	d.CredentialKeyring = opts.Bool("sel-credential-keyring")
+	d.CredentialCommand = opts.String("sel-credential-command")
+	d.DomainName = opts.String("os-domain-name")
+	d.UserDomainName = opts.String("os-user-domain-name")
+	d.UserDomainID = opts.String("os-user-domain-id")
+	d.Region = opts.String("os-region")
+	d.ProjectID = opts.String("os-project-id")
+	d.ProjectName = opts.String("os-project-name")
+	d.ProjectDomainName = opts.String("os-project-domain-name")
+	d.ProjectDomainID = opts.String("os-project-domain-id")
+	d.Proxy = opts.String("sel-proxy")
+	d.CACert = opts.String("os-cacert")
+	d.ClientCert = opts.String("os-cert")
+	d.ClientKey = opts.String("os-key")
+	d.Insecure = opts.Bool("os-insecure")
+
+	if d.Cloud != "" {
+		if err := d.loadCloudConfig(); err != nil {
+			return err
+		}
+	}
+
+	// the secret may be kept outside of the environment
+	if d.CredentialKeyring || d.CredentialCommand != "" {
+		return d.resolveCredentials()
+	}
+	return nil
+}
+
 func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 	// openstack
 	d.ServerName = opts.String("sel-server-name")
@@ -233,17 +552,24 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 	d.FlavorName = opts.String("os-flavor-name")
 	d.ImageID = opts.String("os-image-id")
 	d.ImageName = opts.String("os-image-name")
+	d.ImageTags = opts.StringSlice("sel-image-tag")
+	d.ImageVisibility = opts.String("sel-image-visibility")
+	d.ImageOwner = opts.String("sel-image-owner")
+	d.ImageNameMatch = opts.String("sel-image-name-match")
+	d.ImageSort = opts.String("sel-image-sort")
+	d.ImageFile = opts.String("sel-image-file")
+	d.ImageDeduplicate = opts.Bool("sel-image-dedup")
 	d.NetworkID = opts.String("os-net-id")
 	d.ServerName = opts.String("sel-server-name")
-	d.AuthUrl = opts.String("os-auth-url")
-	d.Username = opts.String("os-username")
-	d.Password = opts.String("os-password")
-	d.DomainName = opts.String("os-domain-name")
-	d.Region = opts.String("os-region")
-	d.ProjectID = opts.String("os-project-id")
 	d.AvailabilityZone = opts.String("os-availability-zone")
+	d.AvailabilityZonePolicy = opts.String("sel-availability-zone-policy")
+	if err := d.setAuthConfigFromFlags(opts); err != nil {
+		return err
+	}
 
 	// ssh
+	d.SSHUser = opts.String("sel-ssh-user")
+	d.SSHPort = opts.Int("sel-ssh-port")
 	d.SSHKeyName = opts.String("sel-ssh-pair-name")
 	d.SSHKeyPath = opts.String("sel-ssh-private-key-path")
 	d.SSHPublicKeyPath = fmt.Sprintf("%s.pub", d.SSHKeyPath)
@@ -251,14 +577,34 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 	// selectel
 	d.RAM = opts.Int("sel-ram")
 	d.CPU = opts.Int("sel-cpu")
+	d.Disk = opts.Int("sel-disk")
+	d.FlavorSwap = opts.Int("sel-flavor-swap")
+	d.FlavorEphemeral = opts.Int("sel-flavor-ephemeral")
+
+	// resell
+	d.ResellAPIKey = opts.String("sel-resell-api-key")
+	d.ResellURL = opts.String("sel-resell-url")
 
 	// volumes
 	d.VolumeSize = opts.Int("sel-volume-size")
 	d.VolumeName = opts.String("sel-volume-name")
 	d.VolumeType = opts.String("sel-volume-type")
+	d.RequireEncryption = opts.Bool("sel-require-encryption")
 
-	// other
-	d.Proxy = opts.String("sel-proxy")
+	// boot
+	d.BootMode = opts.String("sel-boot-mode")
+
+	imageProperties, err := parseKeyValues(opts.StringSlice("sel-image-property"))
+	if err != nil {
+		return err
+	}
+	d.ImageProperties = imageProperties
+
+	flavorExtraSpecs, err := parseKeyValues(opts.StringSlice("sel-flavor-extra-spec"))
+	if err != nil {
+		return err
+	}
+	d.FlavorExtraSpecs = flavorExtraSpecs
 
 	// replace variables if needed
 	if len(d.ServerName) == 0 {
@@ -268,7 +614,19 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 		d.VolumeName = fmt.Sprintf(defaultVolumeName, d.ServerName)
 	}
 	if len(d.VolumeType) == 0 {
-		d.VolumeType = fmt.Sprintf(defaultVolumeType, d.AvailabilityZone)
+		d.VolumeType = d.profile().volumeType
+	}
+	if !d.hasImageOptions() {
+		d.ImageName = d.profile().image
+	}
+	if len(d.ImageNameMatch) == 0 {
+		d.ImageNameMatch = defaultImageNameMatch
+	}
+	if len(d.BootMode) == 0 {
+		d.BootMode = defaultBootMode
+	}
+	if len(d.AvailabilityZonePolicy) == 0 {
+		d.AvailabilityZonePolicy = defaultZonePolicy
 	}
 	if d.SSHKeyPath == "" {
 		currenctUser, _ := user.Current()
@@ -276,16 +634,26 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 		log.Infof("Path to ida_rsa isn't provided. Assuming an existing key at the default location '%s'", d.SSHKeyPath)
 		d.SSHPublicKeyPath = fmt.Sprintf("%s.pub", d.SSHKeyPath)
 	}
-	return d.checkConfig()
+	if err := d.checkConfig(); err != nil {
+		return err
+	}
+
+	// later commands have no secret to recognize the method by
+	d.AuthMethod = d.authMethod()
+	return nil
 }
 
 func (d *Driver) Start() error {
-	d.MustAuthenticateIfNeeded()
+	if err := d.AuthenticateIfNeeded(); err != nil {
+		return err
+	}
 	return d.client.StartServer(d.ServerID)
 }
 
 func (d *Driver) Stop() error {
-	d.MustAuthenticateIfNeeded()
+	if err := d.AuthenticateIfNeeded(); err != nil {
+		return err
+	}
 	return d.client.StopServer(d.ServerID)
 }
 
@@ -298,23 +666,36 @@ func (d *Driver) Kill() error {
 }
 
 func (d *Driver) Remove() (err error) {
-	d.MustAuthenticateIfNeeded()
+	if err := d.AuthenticateIfNeeded(); err != nil {
+		return err
+	}
 	log.Infof("Removing server with id '%s'...", d.ServerID)
 	if err := d.client.RemoveServer(d.ServerID); err != nil {
 		log.Error(err)
 	}
 
-	// wait when we may remove volume
-	d.client.WaitForVolumeStatus(d.VolumeID, "available")
+	// server booted from the image has no volume
+	if d.VolumeID != "" {
+		// wait when we may remove volume
+		d.client.WaitForVolumeStatus(d.VolumeID, "available")
 
-	log.Infof("Removing volume with id '%s'...", d.VolumeID)
-	if err := d.client.DeleteVolume(d.VolumeID); err != nil {
-		log.Error(err)
+		log.Infof("Removing volume with id '%s'...", d.VolumeID)
+		if err := d.client.DeleteVolume(d.VolumeID); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if d.FloatingIPOwned {
+		if err := d.releaseFloatingIP(); err != nil {
+			log.Errorf("Can't release floating ip '%s': %s", d.FloatingIPAddress, err)
+		}
 	}
 
-	log.Info("Removing flavor...")
-	if err := d.client.DeleteVolume(d.FlavorID); err != nil {
-		log.Errorf("Can't remove flavor with id '%s'. Is it public?", d.FlavorID)
+	// flavors created by the driver are shared between machines
+	if d.CustomFlavor {
+		if err := d.removeCustomFlavor(); err != nil {
+			log.Errorf("Can't remove flavor with id '%s': %s", d.FlavorID, err)
+		}
 	}
 
 	log.Info("Removing ssh-key...")
@@ -325,7 +706,9 @@ func (d *Driver) Remove() (err error) {
 }
 
 func (d *Driver) Restart() error {
-	d.MustAuthenticateIfNeeded()
+	if err := d.AuthenticateIfNeeded(); err != nil {
+		return err
+	}
 	return d.client.RestartServer(d.ServerID)
 }
 
@@ -334,21 +717,70 @@ func (d *Driver) PreCreateCheck() (err error) {
 		return err
 	}
 
-	if err := requireFreeFloatingIP(d.client); err != nil {
+	// the machine isn't saved if the check fails, so nothing would remove
+	// the image uploaded for it
+	defer func() {
+		if err != nil && d.uploadedImageID != "" {
+			d.deleteImage(d.uploadedImageID)
+			d.uploadedImageID = ""
+		}
+	}()
+
+	if err := d.storeCredential(); err != nil {
+		return err
+	}
+
+	if err := d.requireFloatingIP(); err != nil {
+		return err
+	}
+
+	// volume types depend on the availability zone
+	if err := d.resolveAvailabilityZone(); err != nil {
 		return err
 	}
 
+	if d.BootMode == bootModeVolume {
+		if err := d.resolveVolumeType(); err != nil {
+			return err
+		}
+	}
+
 	if err := d.resolveNamesAndIds(); err != nil {
 		return err
 	}
 
+	if err := d.resolveSSHUser(); err != nil {
+		return err
+	}
+
 	if err := createPublicKeyIfNeeded(d.client, d.SSHKeyName, d.SSHPublicKeyPath); err != nil {
 		return err
 	}
-	return nil
+
+	// the ip is billed, so it's bought after everything else is checked
+	return d.buyFloatingIPIfNeeded()
 }
 
 func (d *Driver) Create() error {
+	serverOpts := servers.CreateOpts{
+		AvailabilityZone: d.AvailabilityZone,
+		Name:             d.ServerName,
+		FlavorRef:        d.FlavorID,
+		Metadata:         d.serverMetadata(),
+		Networks: []servers.Network{
+			{
+				UUID: d.NetworkID,
+			},
+		},
+	}
+
+	if d.BootMode == bootModeLocal {
+		return d.createServerFromImage(serverOpts)
+	}
+	return d.createServerFromVolume(serverOpts)
+}
+
+func (d *Driver) createServerFromVolume(serverOpts servers.CreateOpts) error {
 	volumeOpts := volumes.CreateOpts{
 		Name:             d.VolumeName,
 		VolumeType:       d.VolumeType,
@@ -367,19 +799,6 @@ func (d *Driver) Create() error {
 	log.Info("Waiting volume AVAILABLE status...")
 	d.client.WaitForVolumeStatus(volume.ID, "available")
 
-	serverOpts := servers.CreateOpts{
-		AvailabilityZone: d.AvailabilityZone,
-		Name:             d.ServerName,
-		FlavorRef:        d.FlavorID,
-		Metadata: map[string]string{
-			"x_sel_server_password_hash": fmt.Sprintf("$6$%s", "server_password_hash"),
-		},
-		Networks: []servers.Network{
-			{
-				UUID: d.NetworkID,
-			},
-		},
-	}
 	serverVolumeOpts := bootfromvolume.CreateOptsExt{
 		CreateOptsBuilder: serverOpts,
 		BlockDevice: []bootfromvolume.BlockDevice{
@@ -405,6 +824,22 @@ func (d *Driver) Create() error {
 	return nil
 }
 
+func (d *Driver) createServerFromImage(serverOpts servers.CreateOpts) error {
+	serverOpts.ImageRef = d.ImageID
+	serverKeyPairOpts := keypairs.CreateOptsExt{
+		CreateOptsBuilder: serverOpts,
+		KeyName:           d.SSHKeyName,
+	}
+
+	server, err := d.client.BootInstanceFromImage(serverKeyPairOpts)
+	if err != nil {
+		return err
+	}
+	log.Info("Booted server from image. ID:", server.ID)
+	d.ServerID = server.ID
+	return nil
+}
+
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -419,6 +854,18 @@ func (d *Driver) GetIP() (string, error) {
 		return d.IPAddress, nil
 	}
 
+	// the ip bought for the machine is reserved for it, so it isn't
+	// among the free ones
+	if d.FloatingIPID != "" {
+		log.Debugf("Trying to attach floating ip '%s'...", d.FloatingIPAddress)
+		if err := d.client.AttachFloatingIP(d.ServerID, d.FloatingIPAddress); err != nil {
+			return "", err
+		}
+		d.IPAddress = d.FloatingIPAddress
+		log.Info("Successfully attached IP", d.IPAddress)
+		return d.IPAddress, nil
+	}
+
 	var err error
 	log.Debug("Trying to attach floating ip...")
 	if d.IPAddress, err = d.client.AttachFirstFreeFloatingIP(d.ServerID); err != nil {
@@ -437,43 +884,101 @@ func (d *Driver) DriverName() string {
 }
 
 func (d *Driver) Authenticate() (err error) {
+	// secrets aren't stored with the machine
+	if err := d.resolveCredentials(); err != nil {
+		return err
+	}
+
+	userDomainID, userDomainName := d.userDomain()
 	opts := openstack.ClientOpts{
 		Credentials: gophercloud.AuthOptions{
 			IdentityEndpoint: d.AuthUrl,
 			Username:         d.Username,
 			Password:         d.Password,
-			DomainName:       d.DomainName,
-			TenantID:         d.ProjectID,
+			DomainID:         userDomainID,
+			DomainName:       userDomainName,
+			Scope:            d.projectScope(),
 		},
 		EndpointOpts: gophercloud.EndpointOpts{
 			Region: d.Region,
 		},
 	}
+
+	// a token can't be combined with the user credentials
+	if d.Token != "" {
+		opts.Credentials = gophercloud.AuthOptions{
+			IdentityEndpoint: d.AuthUrl,
+			TokenID:          d.Token,
+			Scope:            d.projectScope(),
+		}
+	}
+	if d.TokenCache {
+		opts.TokenCache = d.newTokenCache()
+	}
+	if d.usesApplicationCredential() {
+		opts.ApplicationCredential = &openstack.ApplicationCredential{
+			ID:             d.ApplicationCredentialID,
+			Name:           d.ApplicationCredentialName,
+			Secret:         d.ApplicationCredentialSecret,
+			Username:       d.Username,
+			UserDomainID:   userDomainID,
+			UserDomainName: userDomainName,
+		}
+	}
+	if opts.Transport, err = d.transportOpts(); err != nil {
+		return err
+	}
+	if d.client, err = openstack.NewClient(opts); err != nil {
+		if _, ok := err.(gophercloud.ErrDefault401); ok {
+			return fmt.Errorf(errorAuthFailed, d.describeScope(), err)
+		}
+		return err
+	}
+
+	if err := d.checkProjectScope(d.client.GetProject()); err != nil {
+		return err
+	}
+
+	// the region may be derived from the catalog
+	d.Region = d.client.GetRegion()
+	return nil
+}
+
+// transportOpts returns the proxy and TLS options shared by the OpenStack
+// and the Resell API clients.
+func (d *Driver) transportOpts() (openstack.TransportOpts, error) {
+	opts := openstack.TransportOpts{
+		CACertFile: d.CACert,
+		CertFile:   d.ClientCert,
+		KeyFile:    d.ClientKey,
+		Insecure:   d.Insecure,
+	}
 	if len(d.Proxy) > 0 {
-		proxy, err := url.Parse(d.Proxy)
+		proxy, err := openstack.ParseProxyURL(d.Proxy)
 		if err != nil {
-			return err
+			return opts, err
 		}
 
+		log.Debugf("Using proxy %s", openstack.RedactURL(proxy))
 		opts.Proxy = proxy
 	}
-	d.client, err = openstack.NewClient(opts)
-	return err
+	if d.Insecure {
+		log.Warn("TLS certificates of OpenStack endpoints aren't verified")
+	}
+	return opts, nil
 }
 
-func (d *Driver) MustAuthenticateIfNeeded() {
+func (d *Driver) AuthenticateIfNeeded() error {
 	if d.client != nil {
-		return
-	}
-
-	if err := d.Authenticate(); err != nil {
-		log.Error(err)
-		os.Exit(1)
+		return nil
 	}
+	return d.Authenticate()
 }
 
 func (d *Driver) GetState() (state.State, error) {
-	d.MustAuthenticateIfNeeded()
+	if err := d.AuthenticateIfNeeded(); err != nil {
+		return state.None, err
+	}
 	status, err := d.client.GetServerState(d.ServerID)
 	if err != nil {
 		return state.None, err
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	machineConfigFile = "config.json"
+	machineStorageEnv = "MACHINE_STORAGE_PATH"
+)
+
+// machineConfig is a raw representation of the config.json which
+// docker-machine keeps for every host. Only the driver part is decoded,
+// everything else is written back untouched.
+type machineConfig map[string]json.RawMessage
+
+func defaultStoragePath() string {
+	if path := os.Getenv(machineStorageEnv); path != "" {
+		return path
+	}
+	return filepath.Join(mcnutils.GetHomeDir(), ".docker", "machine")
+}
+
+func machineConfigPath(storagePath, name string) string {
+	return filepath.Join(storagePath, "machines", name, machineConfigFile)
+}
+
+// loadMachine reads the driver of the stored machine with the given name.
+func loadMachine(storagePath, name string) (*Driver, error) {
+	data, err := ioutil.ReadFile(machineConfigPath(storagePath, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var config machineConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	var driverName string
+	if err := json.Unmarshal(config["DriverName"], &driverName); err != nil {
+		return nil, err
+	}
+
+	d := NewDriver(name, storagePath)
+	if driverName != d.DriverName() {
+		return nil, fmt.Errorf("machine '%s' is managed by '%s' driver", name, driverName)
+	}
+	if err := json.Unmarshal(config["Driver"], d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// saveMachine updates the driver part of the stored machine config.
+func saveMachine(d *Driver) error {
+	path := machineConfigPath(d.StorePath, d.MachineName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config machineConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	if config["Driver"], err = json.Marshal(d); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(config, "", "    "); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// listMachines reads all stored machines managed by the driver.
+func listMachines(storagePath string) ([]*Driver, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(storagePath, "machines"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []*Driver
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// skip machines of other drivers and broken configs
+		d, err := loadMachine(storagePath, entry.Name())
+		if err != nil {
+			continue
+		}
+		machines = append(machines, d)
+	}
+	return machines, nil
+}
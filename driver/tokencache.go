@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/selectel/docker-machine-driver/openstack"
+	"golang.org/x/crypto/scrypt"
+)
+
+// tokenCacheDir is a directory of the machine storage where tokens are
+// cached, one file for every identity.
+const tokenCacheDir = "selectel-tokens"
+
+// tokenCacheSaltSize is the size of the random salt the cache file starts
+// with, it is followed by the nonce and the encrypted token.
+const tokenCacheSaltSize = 16
+
+// fileTokenCache keeps the token encrypted with a key derived from the
+// secret it was obtained with, so the cache file alone isn't enough
+// to use the token.
+type fileTokenCache struct {
+	path   string
+	secret []byte
+}
+
+// newTokenCache returns the token cache of the identity the driver
+// authenticates with.
+func (d *Driver) newTokenCache() *fileTokenCache {
+	storagePath := d.StorePath
+	if storagePath == "" {
+		storagePath = defaultStoragePath()
+	}
+
+	// the project id is recorded after the first authentication, so it
+	// doesn't identify a project given by name or by the application credential
+	project := d.ProjectID
+	if d.ProjectName != "" {
+		project = d.ProjectName
+	}
+	if d.usesApplicationCredential() {
+		project = ""
+	}
+
+	domainID, domainName := d.userDomain()
+	identity := strings.Join([]string{d.AuthUrl, domainID, domainName, d.Username,
+		d.ApplicationCredentialID, d.ApplicationCredentialName, project}, "\x00")
+	name := sha256.Sum256([]byte(identity))
+
+	secret := strings.Join([]string{identity, d.Password, d.ApplicationCredentialSecret, d.Token}, "\x00")
+
+	return &fileTokenCache{
+		path:   filepath.Join(storagePath, tokenCacheDir, hex.EncodeToString(name[:])),
+		secret: []byte(secret),
+	}
+}
+
+func (cache *fileTokenCache) Load() (*openstack.CachedToken, error) {
+	data, err := ioutil.ReadFile(cache.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < tokenCacheSaltSize {
+		return nil, errors.New("token cache is corrupted")
+	}
+	salt, data := data[:tokenCacheSaltSize], data[tokenCacheSaltSize:]
+
+	gcm, err := cache.cipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("token cache is corrupted")
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var token openstack.CachedToken
+	if err := json.Unmarshal(plain, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (cache *fileTokenCache) Save(token *openstack.CachedToken) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, tokenCacheSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+
+	gcm, err := cache.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cache.path), 0700); err != nil {
+		return err
+	}
+	data := append(salt, nonce...)
+	return ioutil.WriteFile(cache.path, gcm.Seal(data, nonce, plain, nil), 0600)
+}
+
+// cipher derives the key from the secret with scrypt, so a stolen cache
+// file doesn't allow to guess a weak password quickly.
+func (cache *fileTokenCache) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(cache.secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
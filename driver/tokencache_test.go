@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+func TestTokenCache(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "token-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	d := NewDriver("machine", storePath)
+	d.AuthUrl = "https://api.example.com/identity/v3"
+	d.Username = "user"
+	d.Password = "secret"
+	d.ProjectID = "p1"
+
+	token, err := d.newTokenCache().Load()
+	if token != nil || err != nil {
+		t.Fatalf("Load() of an empty cache = %v, %v, expected no token and no error", token, err)
+	}
+
+	saved := &openstack.CachedToken{
+		ID:        "token",
+		ExpiresAt: time.Date(2018, 5, 15, 12, 0, 0, 0, time.UTC),
+		Catalog: &tokens.ServiceCatalog{Entries: []tokens.CatalogEntry{
+			{Type: "compute", Endpoints: []tokens.Endpoint{{RegionID: "ru-1", Interface: "public", URL: "https://compute.example.com"}}},
+		}},
+		Project: &tokens.Project{ID: "p1", Name: "project"},
+	}
+	if err := d.newTokenCache().Save(saved); err != nil {
+		t.Fatalf("Save() returned error: %s", err)
+	}
+
+	token, err = d.newTokenCache().Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(token, saved) {
+		t.Errorf("Load() = %+v, expected %+v", token, saved)
+	}
+
+	// every save uses a new salt
+	first, err := ioutil.ReadFile(d.newTokenCache().path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.newTokenCache().Save(saved); err != nil {
+		t.Fatalf("Save() returned error: %s", err)
+	}
+	second, err := ioutil.ReadFile(d.newTokenCache().path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first[:tokenCacheSaltSize], second[:tokenCacheSaltSize]) {
+		t.Errorf("Save() reused the salt %x", first[:tokenCacheSaltSize])
+	}
+
+	// the same identity with another password uses the same file
+	d.Password = "another"
+	token, err = d.newTokenCache().Load()
+	if err == nil {
+		t.Errorf("Load() with another password = %+v, expected error", token)
+	}
+
+	d.Username = "another"
+	token, err = d.newTokenCache().Load()
+	if token != nil || err != nil {
+		t.Errorf("Load() of another user = %v, %v, expected no token and no error", token, err)
+	}
+}
@@ -0,0 +1,154 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+const (
+	errorUnknownZone       = "Availability zone '%s' isn't available for %s. Available zones: %s"
+	errorNoZones           = "There are no availability zones available for %s"
+	errorUnknownZonePolicy = "Availability zone policy '%s' is unknown, use '%s' or '%s'"
+)
+
+const (
+	zonePolicyFirst     = "first"
+	zonePolicyLeastUsed = "least-used"
+	defaultZonePolicy   = zonePolicyFirst
+)
+
+// availabilityZone describes whether servers and volumes may be created
+// in the zone.
+type availabilityZone struct {
+	Name    string `json:"name"`
+	Compute bool   `json:"compute"`
+	Volume  bool   `json:"volume"`
+}
+
+// availabilityZones returns compute and block storage availability zones
+// of the region sorted by name.
+func availabilityZones(client openstack.Client) ([]availabilityZone, error) {
+	computeZones, err := client.GetAvailabilityZones()
+	if err != nil {
+		return nil, err
+	}
+	volumeZones, err := client.GetVolumeAvailabilityZones()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*availabilityZone)
+	zone := func(name string) *availabilityZone {
+		if byName[name] == nil {
+			byName[name] = &availabilityZone{Name: name}
+		}
+		return byName[name]
+	}
+	for _, computeZone := range computeZones {
+		zone(computeZone.ZoneName).Compute = computeZone.ZoneState.Available
+	}
+	for _, volumeZone := range volumeZones {
+		zone(volumeZone.ZoneName).Volume = volumeZone.ZoneState.Available
+	}
+
+	zones := make([]availabilityZone, 0, len(byName))
+	for _, zone := range byName {
+		zones = append(zones, *zone)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Name < zones[j].Name
+	})
+	return zones, nil
+}
+
+// zoneUsage describes what the machine needs from its availability zone.
+func (d *Driver) zoneUsage() string {
+	if d.BootMode == bootModeVolume {
+		return "servers and volumes"
+	}
+	return "servers"
+}
+
+// usableZones returns names of the zones where the machine may be created.
+// Servers booted from a volume need the volume in the same zone.
+func (d *Driver) usableZones() ([]string, error) {
+	zones, err := availabilityZones(d.client)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, zone := range zones {
+		if zone.Compute && (zone.Volume || d.BootMode != bootModeVolume) {
+			names = append(names, zone.Name)
+		}
+	}
+	return names, nil
+}
+
+// resolveAvailabilityZone validates the given availability zone or picks
+// one according to the zone policy if none was given. A volume type of
+// a zone like "fast.ru-1a" picks its zone.
+func (d *Driver) resolveAvailabilityZone() error {
+	zones, err := d.usableZones()
+	if err != nil {
+		return err
+	}
+
+	if d.AvailabilityZone == "" && d.BootMode == bootModeVolume && d.profile().zoneVolumeTypes {
+		d.AvailabilityZone = volumeTypeZone(d.VolumeType)
+	}
+
+	if d.AvailabilityZone != "" {
+		for _, zone := range zones {
+			if zone == d.AvailabilityZone {
+				return nil
+			}
+		}
+		return fmt.Errorf(errorUnknownZone, d.AvailabilityZone, d.zoneUsage(), strings.Join(zones, ", "))
+	}
+
+	if len(zones) == 0 {
+		return fmt.Errorf(errorNoZones, d.zoneUsage())
+	}
+
+	d.AvailabilityZone = zones[0]
+	if d.AvailabilityZonePolicy == zonePolicyLeastUsed {
+		usage, err := d.zoneMachines()
+		if err != nil {
+			return err
+		}
+		for _, zone := range zones {
+			if usage[zone] < usage[d.AvailabilityZone] {
+				d.AvailabilityZone = zone
+			}
+		}
+	}
+
+	log.Infof("Using availability zone '%s'", d.AvailabilityZone)
+	return nil
+}
+
+// zoneMachines counts stored machines of the same project and region
+// in every availability zone.
+func (d *Driver) zoneMachines() (map[string]int, error) {
+	machines, err := listMachines(d.StorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]int)
+	for _, machine := range machines {
+		if machine.MachineName == d.MachineName {
+			continue
+		}
+		if machine.AuthUrl == d.AuthUrl && machine.ProjectID == d.ProjectID && machine.Region == d.Region {
+			usage[machine.AvailabilityZone]++
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+// zonesClient returns the given availability zones, other methods
+// of the client aren't implemented.
+type zonesClient struct {
+	openstack.Client
+	computeZones []availabilityzones.AvailabilityZone
+	volumeZones  []availabilityzones.AvailabilityZone
+}
+
+func (client *zonesClient) GetAvailabilityZones() ([]availabilityzones.AvailabilityZone, error) {
+	return client.computeZones, nil
+}
+
+func (client *zonesClient) GetVolumeAvailabilityZones() ([]availabilityzones.AvailabilityZone, error) {
+	return client.volumeZones, nil
+}
+
+func testZone(name string, available bool) availabilityzones.AvailabilityZone {
+	return availabilityzones.AvailabilityZone{
+		ZoneName:  name,
+		ZoneState: availabilityzones.ZoneState{Available: available},
+	}
+}
+
+func TestUsableZones(t *testing.T) {
+	client := &zonesClient{
+		computeZones: []availabilityzones.AvailabilityZone{
+			testZone("ru-3b", true),
+			testZone("ru-3a", true),
+			testZone("ru-3c", false),
+			testZone("ru-3d", true),
+		},
+		volumeZones: []availabilityzones.AvailabilityZone{
+			testZone("ru-3a", true),
+			testZone("ru-3b", false),
+			testZone("ru-3c", true),
+			testZone("ru-3e", true),
+		},
+	}
+
+	tests := []struct {
+		bootMode string
+		expected []string
+	}{
+		{
+			bootMode: bootModeVolume,
+			expected: []string{"ru-3a"},
+		},
+		{
+			bootMode: bootModeLocal,
+			expected: []string{"ru-3a", "ru-3b", "ru-3d"},
+		},
+	}
+
+	for _, test := range tests {
+		d := &Driver{BootMode: test.bootMode, client: client}
+		zones, err := d.usableZones()
+		if err != nil {
+			t.Errorf("usableZones() in %s boot mode returned error: %s", test.bootMode, err)
+			continue
+		}
+		if !reflect.DeepEqual(zones, test.expected) {
+			t.Errorf("usableZones() in %s boot mode = %q, expected %q", test.bootMode, zones, test.expected)
+		}
+	}
+}
+
+func TestResolveAvailabilityZoneFromVolumeType(t *testing.T) {
+	client := &zonesClient{
+		computeZones: []availabilityzones.AvailabilityZone{testZone("ru-3a", true), testZone("ru-3b", true)},
+		volumeZones:  []availabilityzones.AvailabilityZone{testZone("ru-3a", true), testZone("ru-3b", true)},
+	}
+
+	tests := []struct {
+		volumeType string
+		expected   string
+		isError    bool
+	}{
+		{
+			volumeType: "fast",
+			expected:   "ru-3a",
+		},
+		{
+			volumeType: "fast.ru-3b",
+			expected:   "ru-3b",
+		},
+		{
+			volumeType: "fast.ru-3c",
+			isError:    true,
+		},
+	}
+
+	for _, test := range tests {
+		d := &Driver{BootMode: bootModeVolume, VolumeType: test.volumeType, client: client}
+		err := d.resolveAvailabilityZone()
+		if test.isError {
+			if err == nil {
+				t.Errorf("resolveAvailabilityZone() with volume type %q returned no error", test.volumeType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveAvailabilityZone() with volume type %q returned error: %s", test.volumeType, err)
+			continue
+		}
+		if d.AvailabilityZone != test.expected {
+			t.Errorf("resolveAvailabilityZone() with volume type %q = %q, expected %q", test.volumeType, d.AvailabilityZone, test.expected)
+		}
+	}
+}
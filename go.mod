@@ -8,4 +8,5 @@ require (
 	github.com/sirupsen/logrus v1.0.5
 	golang.org/x/crypto v0.0.0-20180514165030-2fc4c88bf43f
 	golang.org/x/sys v0.0.0-20180514143608-7c87d13f8e83
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,265 @@
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+)
+
+const (
+	errorNoRegions       = "No region in the service catalog offers all of the %s services"
+	errorRegionRequired  = "Region must be specified, available regions: %s"
+	errorUnknownRegion   = "Region '%s' doesn't offer all of the %s services. Available regions: %s"
+	errorRegionSuggested = "Region '%s' doesn't offer all of the %s services. Did you mean '%s'?"
+	errorNoProjectScope  = "Token isn't scoped to a project, the project must be specified by id or by name and domain"
+)
+
+// requiredServices are the catalog service types the client needs
+// in its region. Any type of the alternatives offers the service, the
+// volume API v2 is also served by volumev3 endpoints.
+var requiredServices = [][]string{{"compute"}, {"volumev2", "volumev3"}, {"network"}, {"image"}}
+
+// requiredServicesNames lists the required services for error messages.
+func requiredServicesNames() string {
+	names := make([]string, 0, len(requiredServices))
+	for _, alternatives := range requiredServices {
+		names = append(names, strings.Join(alternatives, " or "))
+	}
+	return strings.Join(names, ", ")
+}
+
+// createToken requests a Keystone v3 token together with the service
+// catalog and the project the token is scoped to.
+func createToken(provider *gophercloud.ProviderClient, opts tokens.AuthOptionsBuilder) (*CachedToken, error) {
+	identityClient, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := tokens.Create(identityClient, opts)
+	token, err := result.ExtractToken()
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, err := result.ExtractServiceCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	// domain scoped and unscoped tokens have no project
+	project, err := result.ExtractProject()
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, errors.New(errorNoProjectScope)
+	}
+
+	return &CachedToken{
+		ID:        token.ID,
+		ExpiresAt: token.ExpiresAt,
+		Catalog:   catalog,
+		Project:   project,
+	}, nil
+}
+
+// authenticate authenticates the provider like openstack.Authenticate does
+// for Keystone v3, but returns the token to inspect the catalog regions
+// and the project. A valid token from the cache is used without contacting
+// Keystone.
+func authenticate(provider *gophercloud.ProviderClient, opts tokens.AuthOptionsBuilder, cache TokenCache) (*CachedToken, error) {
+	if cached := loadToken(cache); cached != nil {
+		provider.TokenID = cached.ID
+		setEndpointLocator(provider, cached.Catalog)
+
+		// the cached token may have been revoked
+		setReauthFunc(provider, opts, cache)
+		return cached, nil
+	}
+
+	token, err := createToken(provider, opts)
+	if err != nil {
+		return nil, err
+	}
+	provider.TokenID = token.ID
+	saveToken(cache, token)
+
+	if opts.CanReauth() || cache != nil {
+		setReauthFunc(provider, opts, cache)
+	}
+	setEndpointLocator(provider, token.Catalog)
+	return token, nil
+}
+
+func setEndpointLocator(provider *gophercloud.ProviderClient, catalog *tokens.ServiceCatalog) {
+	provider.EndpointLocator = func(opts gophercloud.EndpointOpts) (string, error) {
+		return openstack.V3EndpointURL(catalog, opts)
+	}
+}
+
+// setReauthFunc makes the provider request a new token when the current
+// one is rejected.
+func setReauthFunc(provider *gophercloud.ProviderClient, opts tokens.AuthOptionsBuilder, cache TokenCache) {
+	// reauthenticate with a copy of the provider which can't reauthenticate
+	// itself, so an expired password doesn't cause endless retries
+	throwaway := *provider
+	throwaway.ReauthFunc = nil
+	throwaway.TokenID = ""
+	provider.ReauthFunc = func() error {
+		token, err := createToken(&throwaway, opts)
+		if err != nil {
+			return err
+		}
+		provider.TokenID = token.ID
+		saveToken(cache, token)
+		return nil
+	}
+}
+
+// catalogRegions returns sorted regions which have public endpoints
+// of all the required services.
+func catalogRegions(catalog *tokens.ServiceCatalog) []string {
+	services := make(map[string]map[string]bool)
+	for _, entry := range catalog.Entries {
+		for _, endpoint := range entry.Endpoints {
+			if endpoint.Interface != string(gophercloud.AvailabilityPublic) {
+				continue
+			}
+
+			region := endpoint.RegionID
+			if region == "" {
+				region = endpoint.Region
+			}
+			if services[region] == nil {
+				services[region] = make(map[string]bool)
+			}
+			services[region][entry.Type] = true
+		}
+	}
+
+	var regions []string
+	for region, types := range services {
+		offersAll := true
+		for _, alternatives := range requiredServices {
+			offersAny := false
+			for _, serviceType := range alternatives {
+				offersAny = offersAny || types[serviceType]
+			}
+			offersAll = offersAll && offersAny
+		}
+		if offersAll {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// resolveRegion validates the requested region against the catalog regions.
+// Without a requested region the only catalog region is used, or the one
+// mentioned in the host name of the authentication URL.
+func resolveRegion(region, authURL string, regions []string) (string, error) {
+	services := requiredServicesNames()
+	if len(regions) == 0 {
+		return "", fmt.Errorf(errorNoRegions, services)
+	}
+
+	if region != "" {
+		for _, candidate := range regions {
+			if candidate == region {
+				return region, nil
+			}
+		}
+		for _, candidate := range regions {
+			if normalizeRegion(candidate) == normalizeRegion(region) {
+				return "", fmt.Errorf(errorRegionSuggested, region, services, candidate)
+			}
+		}
+		return "", fmt.Errorf(errorUnknownRegion, region, services, strings.Join(regions, ", "))
+	}
+
+	if len(regions) == 1 {
+		return regions[0], nil
+	}
+
+	if u, err := url.Parse(authURL); err == nil {
+		for _, label := range strings.Split(u.Hostname(), ".") {
+			for _, candidate := range regions {
+				if normalizeRegion(candidate) == normalizeRegion(label) {
+					return candidate, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf(errorRegionRequired, strings.Join(regions, ", "))
+}
+
+// normalizeRegion makes regions like "ru-1", "RU1" and "ru_1" comparable.
+func normalizeRegion(region string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "", ".", "").Replace(region))
+}
+
+// ApplicationCredential authenticates with a Keystone v3 application
+// credential. The credential is given either by ID or by name together
+// with its user, the token is scoped to the credential project.
+type ApplicationCredential struct {
+	ID             string
+	Name           string
+	Secret         string
+	UserID         string
+	Username       string
+	UserDomainID   string
+	UserDomainName string
+	AllowReauth    bool
+}
+
+// ToTokenV3CreateMap implements tokens.AuthOptionsBuilder.
+func (opts *ApplicationCredential) ToTokenV3CreateMap(map[string]interface{}) (map[string]interface{}, error) {
+	credential := map[string]interface{}{
+		"secret": opts.Secret,
+	}
+	if opts.ID != "" {
+		credential["id"] = opts.ID
+	} else {
+		user := make(map[string]interface{})
+		if opts.UserID != "" {
+			user["id"] = opts.UserID
+		} else {
+			user["name"] = opts.Username
+			if opts.UserDomainID != "" {
+				user["domain"] = map[string]interface{}{"id": opts.UserDomainID}
+			} else {
+				user["domain"] = map[string]interface{}{"name": opts.UserDomainName}
+			}
+		}
+		credential["name"] = opts.Name
+		credential["user"] = user
+	}
+
+	return map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods":                []string{"application_credential"},
+				"application_credential": credential,
+			},
+		},
+	}, nil
+}
+
+// ToTokenV3ScopeMap implements tokens.AuthOptionsBuilder. Application
+// credentials can't be rescoped.
+func (opts *ApplicationCredential) ToTokenV3ScopeMap() (map[string]interface{}, error) {
+	return nil, nil
+}
+
+// CanReauth implements tokens.AuthOptionsBuilder.
+func (opts *ApplicationCredential) CanReauth() bool {
+	return opts.AllowReauth
+}
@@ -0,0 +1,135 @@
+package openstack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+)
+
+// catalogEntry returns a catalog entry of the service type with public
+// endpoints in the regions.
+func catalogEntry(serviceType string, regions ...string) tokens.CatalogEntry {
+	entry := tokens.CatalogEntry{Type: serviceType}
+	for _, region := range regions {
+		entry.Endpoints = append(entry.Endpoints, tokens.Endpoint{
+			RegionID:  region,
+			Interface: "public",
+		})
+	}
+	return entry
+}
+
+func TestCatalogRegions(t *testing.T) {
+	tests := []struct {
+		entries  []tokens.CatalogEntry
+		expected []string
+	}{
+		{
+			entries: []tokens.CatalogEntry{
+				catalogEntry("compute", "ru-1", "ru-2", "ru-3"),
+				catalogEntry("volumev2", "ru-1", "ru-3"),
+				catalogEntry("network", "ru-1", "ru-2", "ru-3"),
+				catalogEntry("image", "ru-3", "ru-1"),
+			},
+			expected: []string{"ru-1", "ru-3"},
+		},
+		{
+			entries: []tokens.CatalogEntry{
+				catalogEntry("compute", "ru-1", "ru-2"),
+				catalogEntry("volumev2", "ru-1"),
+				catalogEntry("volumev3", "ru-2"),
+				catalogEntry("network", "ru-1", "ru-2"),
+				catalogEntry("image", "ru-1", "ru-2"),
+			},
+			expected: []string{"ru-1", "ru-2"},
+		},
+		{
+			entries: []tokens.CatalogEntry{
+				catalogEntry("compute", "ru-1"),
+				catalogEntry("volume", "ru-1"),
+				catalogEntry("network", "ru-1"),
+				catalogEntry("image", "ru-1"),
+			},
+			expected: nil,
+		},
+		{
+			entries: []tokens.CatalogEntry{
+				{Type: "compute", Endpoints: []tokens.Endpoint{{Region: "ru-1", Interface: "public"}}},
+				{Type: "volumev3", Endpoints: []tokens.Endpoint{{Region: "ru-1", Interface: "public"}}},
+				{Type: "network", Endpoints: []tokens.Endpoint{{Region: "ru-1", Interface: "public"}}},
+				{Type: "image", Endpoints: []tokens.Endpoint{{Region: "ru-1", Interface: "internal"}}},
+			},
+			expected: nil,
+		},
+	}
+
+	for i, test := range tests {
+		regions := catalogRegions(&tokens.ServiceCatalog{Entries: test.entries})
+		if !reflect.DeepEqual(regions, test.expected) {
+			t.Errorf("catalog %d has regions %q, expected %q", i, regions, test.expected)
+		}
+	}
+}
+
+func TestResolveRegion(t *testing.T) {
+	tests := []struct {
+		region   string
+		authURL  string
+		regions  []string
+		expected string
+		isError  bool
+	}{
+		{
+			region:   "ru-3",
+			regions:  []string{"ru-1", "ru-3"},
+			expected: "ru-3",
+		},
+		{
+			region:  "RU3",
+			regions: []string{"ru-1", "ru-3"},
+			isError: true,
+		},
+		{
+			region:  "ru-9",
+			regions: []string{"ru-1", "ru-3"},
+			isError: true,
+		},
+		{
+			regions:  []string{"ru-1"},
+			expected: "ru-1",
+		},
+		{
+			authURL:  "https://ru-3.cloud.example.com:5000/v3",
+			regions:  []string{"ru-1", "ru-3"},
+			expected: "ru-3",
+		},
+		{
+			authURL: "https://api.cloud.example.com:5000/v3",
+			regions: []string{"ru-1", "ru-3"},
+			isError: true,
+		},
+		{
+			region:  "ru-1",
+			regions: nil,
+			isError: true,
+		},
+	}
+
+	for _, test := range tests {
+		region, err := resolveRegion(test.region, test.authURL, test.regions)
+		if test.isError {
+			if err == nil {
+				t.Errorf("resolveRegion(%q, %q, %q) returned no error", test.region, test.authURL, test.regions)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveRegion(%q, %q, %q) returned error: %s", test.region, test.authURL, test.regions, err)
+			continue
+		}
+		if region != test.expected {
+			t.Errorf("resolveRegion(%q, %q, %q) = %q, expected %q", test.region, test.authURL, test.regions, region, test.expected)
+		}
+	}
+}
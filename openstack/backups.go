@@ -0,0 +1,167 @@
+package openstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// backupTimeout is measured in seconds, the whole volume is copied
+// to the backup storage.
+const backupTimeout = 60 * 60
+
+// Backup represents a Cinder volume backup.
+type Backup struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	VolumeID    string    `json:"volume_id"`
+	Size        int       `json:"size"`
+	Incremental bool      `json:"is_incremental"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+func (r *Backup) UnmarshalJSON(b []byte) error {
+	type tmp Backup
+	var s struct {
+		tmp
+		CreatedAt gophercloud.JSONRFC3339MilliNoZ `json:"created_at"`
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*r = Backup(s.tmp)
+	r.CreatedAt = time.Time(s.CreatedAt)
+	return nil
+}
+
+// BackupCreateOpts contains options for creating a backup of a volume.
+// Force allows backing up volumes which are attached to a server.
+type BackupCreateOpts struct {
+	VolumeID    string `json:"volume_id" required:"true"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Incremental bool   `json:"incremental,omitempty"`
+	Force       bool   `json:"force,omitempty"`
+}
+
+func (opts BackupCreateOpts) ToBackupCreateMap() (map[string]interface{}, error) {
+	return gophercloud.BuildRequestBody(opts, "backup")
+}
+
+// BackupRestoreOpts contains options for restoring a backup. A new volume
+// is created when VolumeID is empty.
+type BackupRestoreOpts struct {
+	VolumeID string `json:"volume_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+func (opts BackupRestoreOpts) ToBackupRestoreMap() (map[string]interface{}, error) {
+	return gophercloud.BuildRequestBody(opts, "restore")
+}
+
+// BackupRestore describes the volume the backup is restored to.
+type BackupRestore struct {
+	BackupID   string `json:"backup_id"`
+	VolumeID   string `json:"volume_id"`
+	VolumeName string `json:"volume_name"`
+}
+
+func (client *GenericClient) CreateBackup(opts BackupCreateOpts) (*Backup, error) {
+	b, err := opts.ToBackupCreateMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Backup Backup `json:"backup"`
+	}
+	_, err = client.BlockStorage.Post(client.BlockStorage.ServiceURL("backups"), b, &r, &gophercloud.RequestOpts{
+		OkCodes: []int{202},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r.Backup, nil
+}
+
+func (client *GenericClient) GetBackup(backupID string) (*Backup, error) {
+	var r struct {
+		Backup Backup `json:"backup"`
+	}
+	if _, err := client.BlockStorage.Get(client.BlockStorage.ServiceURL("backups", backupID), &r, nil); err != nil {
+		return nil, err
+	}
+	return &r.Backup, nil
+}
+
+func (client *GenericClient) ListBackups() ([]Backup, error) {
+	var r struct {
+		Backups []Backup `json:"backups"`
+	}
+	if _, err := client.BlockStorage.Get(client.BlockStorage.ServiceURL("backups", "detail"), &r, nil); err != nil {
+		return nil, err
+	}
+	return r.Backups, nil
+}
+
+func (client *GenericClient) DeleteBackup(backupID string) error {
+	_, err := client.BlockStorage.Delete(client.BlockStorage.ServiceURL("backups", backupID), &gophercloud.RequestOpts{
+		OkCodes: []int{202},
+	})
+	return err
+}
+
+func (client *GenericClient) RestoreBackup(backupID string, opts BackupRestoreOpts) (*BackupRestore, error) {
+	b, err := opts.ToBackupRestoreMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Restore BackupRestore `json:"restore"`
+	}
+	_, err = client.BlockStorage.Post(client.BlockStorage.ServiceURL("backups", backupID, "restore"), b, &r, &gophercloud.RequestOpts{
+		OkCodes: []int{202},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r.Restore, nil
+}
+
+// WaitForVolumeAvailable waits until the volume, e.g. the one a backup
+// is restored into, becomes available. It fails on error states like
+// error_restoring instead of waiting for the timeout.
+func (client *GenericClient) WaitForVolumeAvailable(volumeID string) error {
+	return gophercloud.WaitFor(backupTimeout, func() (bool, error) {
+		volume, err := client.GetVolume(volumeID)
+		if err != nil {
+			return false, err
+		}
+
+		if strings.HasPrefix(volume.Status, "error") {
+			return false, fmt.Errorf("volume '%s' is in '%s' state", volumeID, volume.Status)
+		}
+		return volume.Status == "available", nil
+	})
+}
+
+func (client *GenericClient) WaitForBackupStatus(backupID, status string) error {
+	return gophercloud.WaitFor(backupTimeout, func() (bool, error) {
+		backup, err := client.GetBackup(backupID)
+		if err != nil {
+			return false, err
+		}
+
+		if backup.Status == "error" {
+			return false, fmt.Errorf("backup '%s' is in error state", backupID)
+		}
+		return backup.Status == status, nil
+	})
+}
@@ -3,32 +3,54 @@ package openstack
 import (
 	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
+	"io"
+	"strings"
 	"time"
 
+	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/version"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v2/volumes"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumetypes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/bootfromvolume"
 	cmp_fips "github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/floatingips"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/startstop"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
-	"github.com/gophercloud/gophercloud/openstack/compute/v2/images"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
 )
 
 type Client interface {
+	GetRegion() string
+	GetProject() *tokens.Project
+
 	CreateVolume(opts volumes.CreateOpts) (*volumes.Volume, error)
+	GetVolume(volumeID string) (*volumes.Volume, error)
 	DeleteVolume(volumeID string) error
 	WaitForVolumeStatus(volumeID, status string) error
+	RetypeVolume(volumeID string, opts RetypeOpts) error
+	WaitForVolumeType(volumeID, volumeType string) error
+
+	CreateBackup(opts BackupCreateOpts) (*Backup, error)
+	GetBackup(backupID string) (*Backup, error)
+	ListBackups() ([]Backup, error)
+	DeleteBackup(backupID string) error
+	RestoreBackup(backupID string, opts BackupRestoreOpts) (*BackupRestore, error)
+	WaitForBackupStatus(backupID, status string) error
+	WaitForVolumeAvailable(volumeID string) error
+	GetVolumeTypes() ([]volumetypes.VolumeType, error)
+	GetVolumeTypeEncryption(volumeTypeID string) (*VolumeTypeEncryption, error)
 
 	BootInstanceFromVolume(opts servers.CreateOptsBuilder) (*servers.Server, error)
+	BootInstanceFromImage(opts servers.CreateOptsBuilder) (*servers.Server, error)
 	DeleteServer(serverID string) error
 	SetServerPassword(serverID string, password string) error
 	GetServerState(serverID string) (string, error)
@@ -36,22 +58,38 @@ type Client interface {
 	RestartServer(serverID string) error
 	StopServer(serverID string) error
 	RemoveServer(serverID string) error
+	GetServerVolumes(serverID string) ([]string, error)
+	ListServers(opts servers.ListOpts) ([]servers.Server, error)
 
 	AttachFloatingIP(serverID, floatingIP string) error
 	AttachFirstFreeFloatingIP(serverID string) (string, error)
 	GetAllFloatingIP() ([]floatingips.FloatingIP, error)
+	ReserveFloatingIP(floatingIPID, machineName string) error
+	WaitForFloatingIP(floatingIPID string) error
+	WaitForFloatingIPStatus(floatingIPID, status string) error
 
 	GetPublicKey(keyPairName string) ([]byte, error)
 	CreateKeyPair(name string, publicKey string) error
 	DeleteKeyPair(name string) error
 
 	GetFlavorBy(name, id *string) (*flavors.Flavor, error)
-	CreateFlavor(name string, cpu, ram int) (*flavors.Flavor, error)
+	ListFlavors() ([]flavors.Flavor, error)
+	CreateFlavor(opts FlavorCreateOpts) (*flavors.Flavor, error)
+	DeleteFlavor(flavorID string) error
 
-	GetImageBy(name, id *string) (*images.Image, error)
+	GetImage(imageID string) (*images.Image, error)
+	ListImages(filter ImageFilter) ([]images.Image, error)
+	CreateImage(opts images.CreateOpts) (*images.Image, error)
+	UploadImageData(imageID string, data io.Reader) error
+	DeleteImage(imageID string) error
+	WaitForImageStatus(imageID string, status images.ImageStatus) error
 
 	GetNetworkID(name string) (string, error)
+	ListNetworks() ([]networks.Network, error)
 	GetSubnets() ([]subnets.Subnet, error)
+
+	GetAvailabilityZones() ([]availabilityzones.AvailabilityZone, error)
+	GetVolumeAvailabilityZones() ([]availabilityzones.AvailabilityZone, error)
 }
 
 type GenericClient struct {
@@ -60,25 +98,74 @@ type GenericClient struct {
 	BlockStorage *gophercloud.ServiceClient
 	Network      *gophercloud.ServiceClient
 	Image        *gophercloud.ServiceClient
+	Region       string
+	Project      *tokens.Project
 }
 
 type ClientOpts struct {
 	Credentials  gophercloud.AuthOptions
 	EndpointOpts gophercloud.EndpointOpts
-	Proxy        *url.URL
+	Transport    TransportOpts
+
+	// ApplicationCredential is used instead of the password credentials
+	// if set, Credentials still provide the identity endpoint.
+	ApplicationCredential *ApplicationCredential
+
+	// TokenCache keeps the token between clients if set.
+	TokenCache TokenCache
 }
 
 const (
 	UserAgent = "docker-machine/v%d"
+
+	// floatingIPTimeout is how long a floating IP may take to show up
+	// in Neutron or to be detached from a deleted server
+	floatingIPTimeout = 5 * 60
+
+	// floatingIPReservedPrefix starts the description of floating IPs
+	// reserved for a machine, they aren't free for other machines
+	floatingIPReservedPrefix = "docker-machine reserved for "
+
+	errorNoFreeFloatingIP = "No free floating ip in project"
 )
 
 func NewClient(opts ClientOpts) (Client, error) {
-	provider, err := openstack.AuthenticatedClient(opts.Credentials)
+	provider, err := openstack.NewClient(opts.Credentials.IdentityEndpoint)
 	if err != nil {
 		return nil, err
 	}
 
+	// service clients share the HTTP client of the provider, so the
+	// transport applies to the identity and all service endpoints
+	transport, err := NewTransport(opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+	provider.HTTPClient.Transport = transport
+
+	var authOpts tokens.AuthOptionsBuilder = &opts.Credentials
+	if opts.ApplicationCredential != nil {
+		authOpts = opts.ApplicationCredential
+	}
+
+	token, err := authenticate(provider, authOpts, opts.TokenCache)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the region before creating service clients, they fail
+	// with a vague endpoint not found error
+	region, err := resolveRegion(opts.EndpointOpts.Region, opts.Credentials.IdentityEndpoint, catalogRegions(token.Catalog))
+	if err != nil {
+		return nil, err
+	}
+	opts.EndpointOpts.Region = region
+
+	// clouds without a volumev2 endpoint serve the v2 API on the volumev3 one
 	blockStorageClient, err := openstack.NewBlockStorageV2(provider, opts.EndpointOpts)
+	if err != nil {
+		blockStorageClient, err = openstack.NewBlockStorageV3(provider, opts.EndpointOpts)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -98,21 +185,27 @@ func NewClient(opts ClientOpts) (Client, error) {
 		return nil, err
 	}
 
-	provider.HTTPClient.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
-	blockStorageClient.HTTPClient.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
-	computeClient.HTTPClient.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
-	networkClient.HTTPClient.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
-	imageClient.HTTPClient.Transport = &http.Transport{Proxy: http.ProxyURL(opts.Proxy)}
-
 	provider.UserAgent.Prepend(fmt.Sprintf(UserAgent, version.APIVersion))
 	return &GenericClient{
 		Compute:      computeClient,
 		BlockStorage: blockStorageClient,
 		Network:      networkClient,
 		Image:        imageClient,
+		Region:       region,
+		Project:      token.Project,
 	}, nil
 }
 
+// GetRegion returns the region the client works in.
+func (client *GenericClient) GetRegion() string {
+	return client.Region
+}
+
+// GetProject returns the project the token of the client is scoped to.
+func (client *GenericClient) GetProject() *tokens.Project {
+	return client.Project
+}
+
 func (client *GenericClient) CreateVolume(opts volumes.CreateOpts) (*volumes.Volume, error) {
 	return volumes.Create(client.BlockStorage, opts).Extract()
 }
@@ -129,6 +222,10 @@ func (client *GenericClient) BootInstanceFromVolume(opts servers.CreateOptsBuild
 	return bootfromvolume.Create(client.Compute, opts).Extract()
 }
 
+func (client *GenericClient) BootInstanceFromImage(opts servers.CreateOptsBuilder) (*servers.Server, error) {
+	return servers.Create(client.Compute, opts).Extract()
+}
+
 func (client *GenericClient) DeleteServer(serverID string) error {
 	return servers.Delete(client.Compute, serverID).Err
 }
@@ -160,6 +257,33 @@ func (client *GenericClient) RemoveServer(serverID string) error {
 	return servers.Delete(client.Compute, serverID).Err
 }
 
+func (client *GenericClient) ListServers(opts servers.ListOpts) ([]servers.Server, error) {
+	allPages, err := servers.List(client.Compute, opts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return servers.ExtractServers(allPages)
+}
+
+func (client *GenericClient) GetServerVolumes(serverID string) ([]string, error) {
+	allPages, err := volumeattach.List(client.Compute, serverID).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := volumeattach.ExtractVolumeAttachments(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeIDs := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		volumeIDs = append(volumeIDs, attachment.VolumeID)
+	}
+	return volumeIDs, nil
+}
+
 func (client *GenericClient) SetServerPassword(serverID string, password string) error {
 	return servers.ChangeAdminPassword(client.Compute, serverID, password).ExtractErr()
 }
@@ -169,6 +293,9 @@ func (client *GenericClient) AttachFirstFreeFloatingIP(serverID string) (string,
 	if err != nil {
 		return "", err
 	}
+	if len(fips) == 0 {
+		return "", errors.New(errorNoFreeFloatingIP)
+	}
 	serverIP := fips[0].FloatingIP
 
 	if err := client.AttachFloatingIP(serverID, serverIP); err != nil {
@@ -184,6 +311,8 @@ func (client *GenericClient) AttachFloatingIP(serverID, floatingIP string) error
 	return cmp_fips.AssociateInstance(client.Compute, serverID, opts).Err
 }
 
+// GetAllFloatingIP returns free floating IPs of the project, which are
+// neither attached nor reserved for a machine.
 func (client *GenericClient) GetAllFloatingIP() ([]floatingips.FloatingIP, error) {
 	opts := floatingips.ListOpts{
 		Status: "down",
@@ -194,7 +323,64 @@ func (client *GenericClient) GetAllFloatingIP() ([]floatingips.FloatingIP, error
 		return nil, err
 	}
 
-	return floatingips.ExtractFloatingIPs(allPages)
+	// the vendored gophercloud doesn't extract descriptions
+	var result struct {
+		FloatingIPs []struct {
+			floatingips.FloatingIP
+			Description string `json:"description"`
+		} `json:"floatingips"`
+	}
+	if err := allPages.(floatingips.FloatingIPPage).ExtractInto(&result); err != nil {
+		return nil, err
+	}
+
+	var fips []floatingips.FloatingIP
+	for _, fip := range result.FloatingIPs {
+		if !strings.HasPrefix(fip.Description, floatingIPReservedPrefix) {
+			fips = append(fips, fip.FloatingIP)
+		}
+	}
+	return fips, nil
+}
+
+// floatingIPDescriptionOpts changes the description of a floating IP.
+type floatingIPDescriptionOpts struct {
+	Description string `json:"description"`
+}
+
+func (opts floatingIPDescriptionOpts) ToFloatingIPUpdateMap() (map[string]interface{}, error) {
+	return gophercloud.BuildRequestBody(opts, "floatingip")
+}
+
+// ReserveFloatingIP marks the floating IP as taken by the machine, so it
+// isn't attached to other machines while it's detached.
+func (client *GenericClient) ReserveFloatingIP(floatingIPID, machineName string) error {
+	opts := floatingIPDescriptionOpts{
+		Description: floatingIPReservedPrefix + machineName,
+	}
+	return floatingips.Update(client.Network, floatingIPID, opts).Err
+}
+
+// WaitForFloatingIP waits until a floating IP allocated outside of Neutron,
+// e.g. bought through the Resell API, shows up in it.
+func (client *GenericClient) WaitForFloatingIP(floatingIPID string) error {
+	return gophercloud.WaitFor(floatingIPTimeout, func() (bool, error) {
+		err := floatingips.Get(client.Network, floatingIPID).Err
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+func (client *GenericClient) WaitForFloatingIPStatus(floatingIPID, status string) error {
+	return gophercloud.WaitFor(floatingIPTimeout, func() (bool, error) {
+		fip, err := floatingips.Get(client.Network, floatingIPID).Extract()
+		if err != nil {
+			return false, err
+		}
+		return fip.Status == status, nil
+	})
 }
 
 func (client *GenericClient) GetPublicKey(keyPairName string) ([]byte, error) {
@@ -218,18 +404,68 @@ func (client *GenericClient) DeleteKeyPair(name string) error {
 
 }
 
-func (client *GenericClient) CreateFlavor(name string, cpu, ram int) (*flavors.Flavor, error) {
-	// TODO (m.kalinin): extract for using local_gb
-	diskValue := 0
+// FlavorCreateOpts contains resources and extra specs of a private flavor.
+// Disk and Ephemeral are measured in GB, RAM and Swap in MB.
+type FlavorCreateOpts struct {
+	Name       string
+	VCPUs      int
+	RAM        int
+	Disk       int
+	Swap       int
+	Ephemeral  int
+	ExtraSpecs map[string]string
+}
+
+func (client *GenericClient) CreateFlavor(opts FlavorCreateOpts) (*flavors.Flavor, error) {
 	isPublic := false
-	opts := flavors.CreateOpts{
-		Name:     name,
-		RAM:      ram,
-		VCPUs:    cpu,
+	createOpts := flavors.CreateOpts{
+		Name:     opts.Name,
+		RAM:      opts.RAM,
+		VCPUs:    opts.VCPUs,
 		IsPublic: &isPublic,
-		Disk:     &diskValue,
+		Disk:     &opts.Disk,
+	}
+	if opts.Swap > 0 {
+		createOpts.Swap = &opts.Swap
 	}
-	return flavors.Create(client.Compute, opts).Extract()
+	if opts.Ephemeral > 0 {
+		createOpts.Ephemeral = &opts.Ephemeral
+	}
+
+	flavor, err := flavors.Create(client.Compute, createOpts).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.ExtraSpecs) > 0 {
+		specs := flavors.ExtraSpecsOpts(opts.ExtraSpecs)
+		if _, err := flavors.CreateExtraSpecs(client.Compute, flavor.ID, specs).Extract(); err != nil {
+			// don't leave a flavor without the requested specs
+			if deleteErr := client.DeleteFlavor(flavor.ID); deleteErr != nil {
+				log.Errorf("Can't remove flavor with id '%s': %s", flavor.ID, deleteErr)
+			}
+			return nil, err
+		}
+	}
+	return flavor, nil
+}
+
+// ListFlavors returns public flavors and private flavors of the project.
+func (client *GenericClient) ListFlavors() ([]flavors.Flavor, error) {
+	opts := flavors.ListOpts{
+		AccessType: flavors.PublicAccess,
+	}
+
+	allPages, err := flavors.ListDetail(client.Compute, opts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return flavors.ExtractFlavors(allPages)
+}
+
+func (client *GenericClient) DeleteFlavor(flavorID string) error {
+	return flavors.Delete(client.Compute, flavorID).ExtractErr()
 }
 
 func GetFlavorByName(client *GenericClient, name string) (*flavors.Flavor, error) {
@@ -256,36 +492,44 @@ func (client *GenericClient) GetFlavorBy(name, id *string) (*flavors.Flavor, err
 	return flavors.Get(client.Compute, *id).Extract()
 }
 
-func GetImageByName(client *GenericClient, name string) (*images.Image, error) {
-	imageID, err := images.IDFromName(client.Compute, name)
+func (client *GenericClient) GetNetworkID(name string) (string, error) {
+	network, err := networks.Get(client.Network, name).Extract()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return network.ID, nil
+}
 
-	image := images.Image{
-		Name: name,
-		ID:   imageID,
+func (client *GenericClient) ListNetworks() ([]networks.Network, error) {
+	allPages, err := networks.List(client.Network, networks.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, err
 	}
-	return &image, nil
+
+	return networks.ExtractNetworks(allPages)
 }
 
-func (client *GenericClient) GetImageBy(name, id *string) (*images.Image, error) {
-	if name == nil && id == nil {
-		return nil, errors.New("image name and image id can't be null")
+// GetAvailabilityZones returns availability zones of the compute service.
+func (client *GenericClient) GetAvailabilityZones() ([]availabilityzones.AvailabilityZone, error) {
+	allPages, err := availabilityzones.List(client.Compute).AllPages()
+	if err != nil {
+		return nil, err
 	}
 
-	if name != nil {
-		return GetImageByName(client, *name)
-	}
-	return images.Get(client.Image, *id).Extract()
+	return availabilityzones.ExtractAvailabilityZones(allPages)
 }
 
-func (client *GenericClient) GetNetworkID(name string) (string, error) {
-	network, err := networks.Get(client.Network, name).Extract()
-	if err != nil {
-		return "", err
+// GetVolumeAvailabilityZones returns availability zones of the block storage
+// service, they are listed in the same format as compute ones.
+func (client *GenericClient) GetVolumeAvailabilityZones() ([]availabilityzones.AvailabilityZone, error) {
+	var result struct {
+		AvailabilityZoneInfo []availabilityzones.AvailabilityZone `json:"availabilityZoneInfo"`
 	}
-	return network.ID, nil
+	url := client.BlockStorage.ServiceURL("os-availability-zone")
+	if _, err := client.BlockStorage.Get(url, &result, nil); err != nil {
+		return nil, err
+	}
+	return result.AvailabilityZoneInfo, nil
 }
 
 func (client *GenericClient) GetSubnets() ([]subnets.Subnet, error)  {
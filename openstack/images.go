@@ -0,0 +1,110 @@
+package openstack
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/imagedata"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+)
+
+// imageTimeout is measured in seconds, Glance may need some time
+// to process uploaded image data.
+const imageTimeout = 30 * 60
+
+// ImageFilter contains conditions for looking up active images through
+// Glance v2. Unlike images.ListOpts it supports filtering by image
+// properties such as os_distro or os_version.
+type ImageFilter struct {
+	Name       string
+	Visibility images.ImageVisibility
+	Owner      string
+	Tags       []string
+	Properties map[string]string
+}
+
+// ToImageListQuery implements images.ListOptsBuilder.
+func (filter ImageFilter) ToImageListQuery() (string, error) {
+	opts := images.ListOpts{
+		Name:       filter.Name,
+		Visibility: filter.Visibility,
+		Owner:      filter.Owner,
+		Tags:       filter.Tags,
+		Status:     images.ImageStatusActive,
+	}
+	q, err := gophercloud.BuildQueryString(opts)
+	if err != nil {
+		return "", err
+	}
+
+	params := q.Query()
+	for key, value := range filter.Properties {
+		params.Add(key, value)
+	}
+	q = &url.URL{RawQuery: params.Encode()}
+	return q.String(), nil
+}
+
+// Matches reports whether the image has all the properties of the filter.
+// Results are checked once again as Glance may skip filters it doesn't know.
+func (filter ImageFilter) Matches(image images.Image) bool {
+	for key, value := range filter.Properties {
+		if fmt.Sprint(image.Properties[key]) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (client *GenericClient) GetImage(imageID string) (*images.Image, error) {
+	return images.Get(client.Image, imageID).Extract()
+}
+
+func (client *GenericClient) ListImages(filter ImageFilter) ([]images.Image, error) {
+	allPages, err := images.List(client.Image, filter).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	allImages, err := images.ExtractImages(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []images.Image
+	for _, image := range allImages {
+		if filter.Matches(image) {
+			matched = append(matched, image)
+		}
+	}
+	return matched, nil
+}
+
+func (client *GenericClient) CreateImage(opts images.CreateOpts) (*images.Image, error) {
+	return images.Create(client.Image, opts).Extract()
+}
+
+func (client *GenericClient) UploadImageData(imageID string, data io.Reader) error {
+	return imagedata.Upload(client.Image, imageID, data).ExtractErr()
+}
+
+func (client *GenericClient) DeleteImage(imageID string) error {
+	return images.Delete(client.Image, imageID).ExtractErr()
+}
+
+func (client *GenericClient) WaitForImageStatus(imageID string, status images.ImageStatus) error {
+	return gophercloud.WaitFor(imageTimeout, func() (bool, error) {
+		image, err := client.GetImage(imageID)
+		if err != nil {
+			return false, err
+		}
+
+		switch image.Status {
+		case images.ImageStatusKilled, images.ImageStatusDeleted:
+			return false, fmt.Errorf("image '%s' is in '%s' state", imageID, image.Status)
+		}
+		return image.Status == status, nil
+	})
+}
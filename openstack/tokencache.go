@@ -0,0 +1,52 @@
+package openstack
+
+import (
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+)
+
+// tokenExpiryMargin is how long before its expiry a cached token is
+// considered stale, so it doesn't expire in the middle of an operation.
+const tokenExpiryMargin = 10 * time.Minute
+
+// CachedToken is a Keystone token kept between client instances together
+// with the service catalog it was issued with and its project.
+type CachedToken struct {
+	ID        string                 `json:"id"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Catalog   *tokens.ServiceCatalog `json:"catalog"`
+	Project   *tokens.Project        `json:"project"`
+}
+
+// TokenCache stores a token between runs of the driver. Load returns nil
+// if there is no token.
+type TokenCache interface {
+	Load() (*CachedToken, error)
+	Save(token *CachedToken) error
+}
+
+// loadToken returns the cached token if it's still valid long enough.
+// The cache is an optimization, so its errors are ignored.
+func loadToken(cache TokenCache) *CachedToken {
+	if cache == nil {
+		return nil
+	}
+
+	token, err := cache.Load()
+	if err != nil || token == nil || token.Catalog == nil || token.Project == nil {
+		return nil
+	}
+	if time.Now().Add(tokenExpiryMargin).After(token.ExpiresAt) {
+		return nil
+	}
+	return token
+}
+
+func saveToken(cache TokenCache, token *CachedToken) {
+	if cache == nil {
+		return
+	}
+
+	cache.Save(token)
+}
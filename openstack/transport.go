@@ -0,0 +1,281 @@
+package openstack
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const errorUnsupportedProxy = "Proxy scheme '%s' isn't supported, use http, https, socks5 or socks5h"
+
+// TransportOpts configure the HTTP transport used for the identity
+// and all service endpoints.
+type TransportOpts struct {
+	// Proxy is used for all endpoints except the ones matching NO_PROXY.
+	// Without it the proxy is taken from HTTPS_PROXY and HTTP_PROXY.
+	Proxy *url.URL
+
+	// CACertFile is a PEM bundle of certificate authorities trusted
+	// instead of the system ones.
+	CACertFile string
+
+	// CertFile and KeyFile are the client certificate and its key
+	// for mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// Insecure disables verification of server certificates.
+	Insecure bool
+}
+
+// NewTransport returns a transport with the settings of http.DefaultTransport
+// and the given proxy and TLS options. Requests are logged in the debug mode
+// and retried when the API is temporarily unavailable.
+func NewTransport(opts TransportOpts) (http.RoundTripper, error) {
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &retryTransport{
+		next: &http.Transport{
+			Proxy: proxyFunc(opts.Proxy),
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       tlsConfig,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}, nil
+}
+
+func (opts TransportOpts) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opts.Insecure,
+	}
+
+	if opts.CACertFile != "" {
+		pem, err := ioutil.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA bundle: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", opts.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// ParseProxyURL parses a proxy URL, a URL without a scheme is an HTTP proxy.
+// Proxy credentials may be given as the user info of the URL.
+func ParseProxyURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	proxy, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf(errorUnsupportedProxy, proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("proxy URL '%s' has no host", RedactURL(proxy))
+	}
+	return proxy, nil
+}
+
+// RedactURL returns the URL with its password replaced, so the URL
+// may be logged.
+func RedactURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	if _, ok := u.User.Password(); !ok {
+		return u.String()
+	}
+
+	redacted := *u
+	redacted.User = url.UserPassword(u.User.Username(), "xxxxx")
+	return redacted.String()
+}
+
+func proxyFunc(proxy *url.URL) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// bypassProxy reports whether the URL matches the NO_PROXY list of
+// host names, domain suffixes, IP addresses and CIDR blocks, which may
+// be followed by a port.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	for _, entry := range strings.Split(strings.ToLower(noProxy), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, block, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && block.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = entryHost
+		}
+
+		// "example.com", ".example.com" and "*.example.com" match the domain
+		// and its subdomains
+		domain := strings.TrimPrefix(strings.TrimPrefix(strings.Trim(entry, "[]"), "*"), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	transportRetries    = 3
+	transportRetryDelay = time.Second
+	maxRetryAfter       = 30 * time.Second
+)
+
+// retryTransport logs requests and retries them on errors which mean
+// the request wasn't processed. Only idempotent requests are retried:
+// a rate limited POST may still have been processed by a proxy or
+// a load balancer in front of the API, so it could create a resource
+// twice.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			log.Debugf("%s %s: %s", req.Method, req.URL, err)
+		} else {
+			log.Debugf("%s %s: %s in %s", req.Method, req.URL, resp.Status, time.Since(start).Round(time.Millisecond))
+		}
+
+		if attempt == transportRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		delay := retryDelay(resp, attempt)
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		// the body of the previous attempt is consumed
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retry := *req
+			retry.Body = body
+			req = &retry
+		}
+
+		log.Debugf("Retrying %s %s in %s", req.Method, req.URL, delay)
+		time.Sleep(delay)
+	}
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay honors Retry-After in seconds, otherwise the delay doubles
+// with every attempt.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			delay := time.Duration(seconds) * time.Second
+			if delay > maxRetryAfter {
+				delay = maxRetryAfter
+			}
+			return delay
+		}
+	}
+	return transportRetryDelay << uint(attempt)
+}
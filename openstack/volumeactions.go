@@ -0,0 +1,79 @@
+package openstack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v2/volumes"
+)
+
+const (
+	// MigrationPolicyOnDemand allows Cinder to migrate volume data to
+	// another backend when the new volume type requires it.
+	MigrationPolicyOnDemand = "on-demand"
+
+	// MigrationPolicyNever makes Cinder reject retypes which need
+	// a migration to another backend.
+	MigrationPolicyNever = "never"
+
+	// volumeRetypeTimeout is measured in seconds; migrating data between
+	// storage backends may take a while for big volumes.
+	volumeRetypeTimeout = 60 * 60
+
+	errorRetypeRejected = "Retype of volume '%s' to '%s' was rejected, it still has type '%s' and status '%s', check the Cinder messages of the volume"
+)
+
+// RetypeOpts contains options for changing the type of an existing volume.
+type RetypeOpts struct {
+	NewType         string `json:"new_type" required:"true"`
+	MigrationPolicy string `json:"migration_policy,omitempty"`
+}
+
+func (opts RetypeOpts) ToVolumeRetypeMap() (map[string]interface{}, error) {
+	return gophercloud.BuildRequestBody(opts, "os-retype")
+}
+
+func retypeVolume(client *gophercloud.ServiceClient, volumeID string, opts RetypeOpts) (r gophercloud.ErrResult) {
+	b, err := opts.ToVolumeRetypeMap()
+	if err != nil {
+		r.Err = err
+		return
+	}
+
+	_, r.Err = client.Post(client.ServiceURL("volumes", volumeID, "action"), b, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{202},
+	})
+	return
+}
+
+func (client *GenericClient) GetVolume(volumeID string) (*volumes.Volume, error) {
+	return volumes.Get(client.BlockStorage, volumeID).Extract()
+}
+
+func (client *GenericClient) RetypeVolume(volumeID string, opts RetypeOpts) error {
+	return retypeVolume(client.BlockStorage, volumeID, opts).ExtractErr()
+}
+
+// WaitForVolumeType waits until the retype of the volume finishes. Cinder
+// marks the volume as retyping before accepting the request and restores
+// its previous status if the retype fails, so a volume which is no longer
+// retyping and still has another type was rejected.
+func (client *GenericClient) WaitForVolumeType(volumeID, volumeType string) error {
+	return gophercloud.WaitFor(volumeRetypeTimeout, func() (bool, error) {
+		volume, err := client.GetVolume(volumeID)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case volume.Status == "retyping":
+			return false, nil
+		case strings.HasPrefix(volume.Status, "error"):
+			return false, fmt.Errorf("volume '%s' is in '%s' state after retype", volumeID, volume.Status)
+		case volume.VolumeType != volumeType:
+			return false, fmt.Errorf(errorRetypeRejected, volumeID, volumeType, volume.VolumeType, volume.Status)
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,39 @@
+package openstack
+
+import "github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumetypes"
+
+// VolumeTypeEncryption describes how volumes of an encrypted volume type
+// are encrypted.
+type VolumeTypeEncryption struct {
+	VolumeTypeID    string `json:"volume_type_id"`
+	EncryptionID    string `json:"encryption_id"`
+	Provider        string `json:"provider"`
+	ControlLocation string `json:"control_location"`
+	Cipher          string `json:"cipher"`
+	KeySize         int    `json:"key_size"`
+}
+
+func (client *GenericClient) GetVolumeTypes() ([]volumetypes.VolumeType, error) {
+	allPages, err := volumetypes.List(client.BlockStorage, volumetypes.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return volumetypes.ExtractVolumeTypes(allPages)
+}
+
+// GetVolumeTypeEncryption returns the encryption spec of the volume type
+// or nil if volumes of the type aren't encrypted.
+func (client *GenericClient) GetVolumeTypeEncryption(volumeTypeID string) (*VolumeTypeEncryption, error) {
+	var encryption VolumeTypeEncryption
+	url := client.BlockStorage.ServiceURL("types", volumeTypeID, "encryption")
+	if _, err := client.BlockStorage.Get(url, &encryption, nil); err != nil {
+		return nil, err
+	}
+
+	// volume types without encryption have an empty spec
+	if encryption.Provider == "" {
+		return nil, nil
+	}
+	return &encryption, nil
+}
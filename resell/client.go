@@ -0,0 +1,161 @@
+package resell
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/machine/libmachine/version"
+	"github.com/selectel/docker-machine-driver/openstack"
+)
+
+const (
+	// DefaultEndpoint is the URL of the Selectel VPC Resell API v2.
+	DefaultEndpoint = "https://api.selectel.ru/vpc/resell/v2"
+
+	// tokenHeader is the header of the API key.
+	tokenHeader = "X-Token"
+)
+
+// Client manages resources of the Selectel VPC which aren't available
+// through OpenStack APIs.
+type Client interface {
+	ListProjects() ([]Project, error)
+	GetProject(projectID string) (*Project, error)
+	CreateProject(opts ProjectCreateOpts) (*Project, error)
+	DeleteProject(projectID string) error
+
+	GetProjectQuotas(projectID string) (Quotas, error)
+	UpdateProjectQuotas(projectID string, quotas Quotas) (Quotas, error)
+
+	ListFloatingIPs() ([]FloatingIP, error)
+	GetFloatingIP(floatingIPID string) (*FloatingIP, error)
+	CreateFloatingIPs(projectID string, opts FloatingIPCreateOpts) ([]FloatingIP, error)
+	DeleteFloatingIP(floatingIPID string) error
+
+	CreateToken(projectID string) (*Token, error)
+}
+
+type GenericClient struct {
+	HTTPClient *http.Client
+	Endpoint   string
+	APIKey     string
+	UserAgent  string
+}
+
+type ClientOpts struct {
+	// APIKey is the key of the Selectel account issued in the control panel.
+	APIKey string
+
+	// Endpoint is DefaultEndpoint if empty.
+	Endpoint string
+
+	// Transport is shared with the OpenStack client.
+	Transport openstack.TransportOpts
+}
+
+// Error is an unexpected response of the API.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d: %s", err.Method, err.URL, err.StatusCode, err.Message)
+}
+
+// IsNotFound reports whether the error means the resource doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func NewClient(opts ClientOpts) (Client, error) {
+	if opts.APIKey == "" {
+		return nil, fmt.Errorf("resell API key is required")
+	}
+
+	transport, err := openstack.NewTransport(opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	return &GenericClient{
+		HTTPClient: &http.Client{Transport: transport},
+		Endpoint:   strings.TrimRight(endpoint, "/"),
+		APIKey:     opts.APIKey,
+		UserAgent:  fmt.Sprintf(openstack.UserAgent, version.APIVersion),
+	}, nil
+}
+
+// request sends the body as JSON and decodes the response into the result,
+// either of them may be nil. Responses other than 2xx are returned as *Error.
+func (client *GenericClient) request(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, client.Endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(tokenHeader, client.APIKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", client.UserAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{
+			Method:     method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Message:    errorMessage(data),
+		}
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// errorMessage extracts the message of an error response, which is
+// {"error": "..."} for most of the API.
+func errorMessage(data []byte) string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error != "" {
+		return body.Error
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,352 @@
+package resell
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testAPIKey = "key"
+
+// testRequest is a request received by the test server.
+type testRequest struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+// newTestClient returns a client of the test server which answers every
+// request with the handler and records the requests.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (Client, *[]testRequest, func()) {
+	var requests []testRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(tokenHeader) != testAPIKey {
+			t.Errorf("%s %s has %s header %q, expected %q", r.Method, r.URL.Path, tokenHeader, r.Header.Get(tokenHeader), testAPIKey)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, testRequest{Method: r.Method, Path: r.URL.Path, Body: string(body)})
+		handler(w, r)
+	}))
+
+	client, err := NewClient(ClientOpts{APIKey: testAPIKey, Endpoint: server.URL + "/"})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return client, &requests, server.Close
+}
+
+// respond returns a handler writing the JSON body with the status.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// checkRequests compares the recorded requests, bodies are compared as JSON.
+func checkRequests(t *testing.T, requests, expected []testRequest) {
+	if len(requests) != len(expected) {
+		t.Errorf("got requests %+v, expected %+v", requests, expected)
+		return
+	}
+	for i := range requests {
+		if requests[i].Method != expected[i].Method || requests[i].Path != expected[i].Path {
+			t.Errorf("got request %s %s, expected %s %s", requests[i].Method, requests[i].Path, expected[i].Method, expected[i].Path)
+		}
+		if !equalJSON(requests[i].Body, expected[i].Body) {
+			t.Errorf("%s %s has body %s, expected %s", requests[i].Method, requests[i].Path, requests[i].Body, expected[i].Body)
+		}
+	}
+}
+
+func equalJSON(first, second string) bool {
+	if first == "" || second == "" {
+		return first == second
+	}
+	var firstValue, secondValue interface{}
+	if json.Unmarshal([]byte(first), &firstValue) != nil || json.Unmarshal([]byte(second), &secondValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(firstValue, secondValue)
+}
+
+func TestNewClientRequiresKey(t *testing.T) {
+	if _, err := NewClient(ClientOpts{}); err == nil {
+		t.Error("NewClient() without an API key returned no error")
+	}
+}
+
+func TestProjects(t *testing.T) {
+	client, requests, closeServer := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/projects" {
+				respond(http.StatusOK, `{"projects": [{"id": "p1", "name": "first", "enabled": true}]}`)(w, r)
+				return
+			}
+			respond(http.StatusOK, `{"project": {"id": "p1", "name": "first", "enabled": true}}`)(w, r)
+		case http.MethodPost:
+			respond(http.StatusOK, `{"project": {"id": "p2", "name": "second", "enabled": true}}`)(w, r)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer closeServer()
+
+	projects, err := client.ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects() returned error: %s", err)
+	}
+	if expected := []Project{{ID: "p1", Name: "first", Enabled: true}}; !reflect.DeepEqual(projects, expected) {
+		t.Errorf("ListProjects() = %+v, expected %+v", projects, expected)
+	}
+
+	project, err := client.GetProject("p1")
+	if err != nil {
+		t.Fatalf("GetProject() returned error: %s", err)
+	}
+	if project.ID != "p1" || project.Name != "first" {
+		t.Errorf("GetProject() = %+v, expected project p1", project)
+	}
+
+	opts := ProjectCreateOpts{
+		Name:   "second",
+		Quotas: Quotas{"compute_cores": {{Region: "ru-1", Zone: "ru-1a", Value: 4}}},
+	}
+	project, err = client.CreateProject(opts)
+	if err != nil {
+		t.Fatalf("CreateProject() returned error: %s", err)
+	}
+	if project.ID != "p2" {
+		t.Errorf("CreateProject() = %+v, expected project p2", project)
+	}
+
+	if err := client.DeleteProject("p2"); err != nil {
+		t.Errorf("DeleteProject() returned error: %s", err)
+	}
+
+	checkRequests(t, *requests, []testRequest{
+		{Method: http.MethodGet, Path: "/projects"},
+		{Method: http.MethodGet, Path: "/projects/p1"},
+		{Method: http.MethodPost, Path: "/projects", Body: `{"project": {"name": "second", "quotas": {"compute_cores": [{"region": "ru-1", "zone": "ru-1a", "value": 4}]}}}`},
+		{Method: http.MethodDelete, Path: "/projects/p2"},
+	})
+}
+
+func TestProjectQuotas(t *testing.T) {
+	client, requests, closeServer := newTestClient(t, respond(http.StatusOK,
+		`{"quotas": {"network_floatingips": [{"region": "ru-1", "value": 2, "used": 1}]}}`))
+	defer closeServer()
+
+	expected := Quotas{"network_floatingips": {{Region: "ru-1", Value: 2, Used: 1}}}
+	quotas, err := client.GetProjectQuotas("p1")
+	if err != nil {
+		t.Fatalf("GetProjectQuotas() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(quotas, expected) {
+		t.Errorf("GetProjectQuotas() = %+v, expected %+v", quotas, expected)
+	}
+
+	quotas, err = client.UpdateProjectQuotas("p1", Quotas{"network_floatingips": {{Region: "ru-1", Value: 2}}})
+	if err != nil {
+		t.Fatalf("UpdateProjectQuotas() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(quotas, expected) {
+		t.Errorf("UpdateProjectQuotas() = %+v, expected %+v", quotas, expected)
+	}
+
+	checkRequests(t, *requests, []testRequest{
+		{Method: http.MethodGet, Path: "/quotas/projects/p1"},
+		{Method: http.MethodPatch, Path: "/quotas/projects/p1", Body: `{"quotas": {"network_floatingips": [{"region": "ru-1", "value": 2}]}}`},
+	})
+}
+
+func TestFloatingIPs(t *testing.T) {
+	floatingIP := `{"id": "f1", "floating_ip_address": "5.5.5.5", "project_id": "p1", "region": "ru-1", "status": "DOWN"}`
+	client, requests, closeServer := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/floatingips/f1":
+			respond(http.StatusOK, `{"floatingip": `+floatingIP+`}`)(w, r)
+		default:
+			respond(http.StatusOK, `{"floatingips": [`+floatingIP+`]}`)(w, r)
+		}
+	})
+	defer closeServer()
+
+	expected := FloatingIP{ID: "f1", FloatingIPAddress: "5.5.5.5", ProjectID: "p1", Region: "ru-1", Status: "DOWN"}
+
+	floatingIPs, err := client.CreateFloatingIPs("p1", FloatingIPCreateOpts{Region: "ru-1"})
+	if err != nil {
+		t.Fatalf("CreateFloatingIPs() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(floatingIPs, []FloatingIP{expected}) {
+		t.Errorf("CreateFloatingIPs() = %+v, expected %+v", floatingIPs, expected)
+	}
+
+	floatingIPs, err = client.ListFloatingIPs()
+	if err != nil {
+		t.Fatalf("ListFloatingIPs() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(floatingIPs, []FloatingIP{expected}) {
+		t.Errorf("ListFloatingIPs() = %+v, expected %+v", floatingIPs, expected)
+	}
+
+	fip, err := client.GetFloatingIP("f1")
+	if err != nil {
+		t.Fatalf("GetFloatingIP() returned error: %s", err)
+	}
+	if !reflect.DeepEqual(*fip, expected) {
+		t.Errorf("GetFloatingIP() = %+v, expected %+v", *fip, expected)
+	}
+
+	if err := client.DeleteFloatingIP("f1"); err != nil {
+		t.Errorf("DeleteFloatingIP() returned error: %s", err)
+	}
+
+	checkRequests(t, *requests, []testRequest{
+		{Method: http.MethodPost, Path: "/floatingips/projects/p1", Body: `{"floatingips": [{"region": "ru-1", "quantity": 1}]}`},
+		{Method: http.MethodGet, Path: "/floatingips"},
+		{Method: http.MethodGet, Path: "/floatingips/f1"},
+		{Method: http.MethodDelete, Path: "/floatingips/f1"},
+	})
+}
+
+func TestCreateToken(t *testing.T) {
+	client, requests, closeServer := newTestClient(t, respond(http.StatusOK, `{"token": {"id": "t1"}}`))
+	defer closeServer()
+
+	token, err := client.CreateToken("p1")
+	if err != nil {
+		t.Fatalf("CreateToken() returned error: %s", err)
+	}
+	if token.ID != "t1" {
+		t.Errorf("CreateToken() = %+v, expected token t1", token)
+	}
+
+	checkRequests(t, *requests, []testRequest{
+		{Method: http.MethodPost, Path: "/tokens", Body: `{"token": {"project_id": "p1"}}`},
+	})
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		message  string
+		notFound bool
+	}{
+		{
+			status:   http.StatusNotFound,
+			body:     `{"error": "project not found"}`,
+			message:  "project not found",
+			notFound: true,
+		},
+		{
+			status:  http.StatusForbidden,
+			body:    `{"error": "invalid token"}`,
+			message: "invalid token",
+		},
+		{
+			status:  http.StatusInternalServerError,
+			body:    "Internal Server Error\n",
+			message: "Internal Server Error",
+		},
+	}
+
+	for _, test := range tests {
+		client, _, closeServer := newTestClient(t, respond(test.status, test.body))
+		_, err := client.GetProject("p1")
+		closeServer()
+
+		apiErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("status %d returned error %v, expected *Error", test.status, err)
+			continue
+		}
+		if apiErr.StatusCode != test.status || apiErr.Message != test.message || apiErr.Method != http.MethodGet {
+			t.Errorf("status %d returned %+v, expected message %q", test.status, apiErr, test.message)
+		}
+		if IsNotFound(err) != test.notFound {
+			t.Errorf("IsNotFound() of status %d = %t, expected %t", test.status, IsNotFound(err), test.notFound)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		status   int
+		call     func(client Client) error
+		requests int
+	}{
+		{
+			status: http.StatusServiceUnavailable,
+			call: func(client Client) error {
+				_, err := client.ListProjects()
+				return err
+			},
+			requests: 3,
+		},
+		{
+			status: http.StatusTooManyRequests,
+			call: func(client Client) error {
+				return client.DeleteFloatingIP("f1")
+			},
+			requests: 3,
+		},
+		{
+			status: http.StatusTooManyRequests,
+			call: func(client Client) error {
+				_, err := client.CreateFloatingIPs("p1", FloatingIPCreateOpts{Region: "ru-1"})
+				return err
+			},
+			requests: 1,
+		},
+		{
+			status: http.StatusServiceUnavailable,
+			call: func(client Client) error {
+				_, err := client.UpdateProjectQuotas("p1", Quotas{})
+				return err
+			},
+			requests: 1,
+		},
+	}
+
+	for _, test := range tests {
+		// the request succeeds on the third attempt
+		attempts := 0
+		client, requests, closeServer := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts < 3 {
+				w.Header().Set("Retry-After", "0")
+				respond(test.status, `{"error": "try again"}`)(w, r)
+				return
+			}
+			respond(http.StatusOK, `{}`)(w, r)
+		})
+		err := test.call(client)
+		closeServer()
+
+		if len(*requests) != test.requests {
+			t.Errorf("status %d: got %d requests %+v, expected %d", test.status, len(*requests), *requests, test.requests)
+			continue
+		}
+		if test.requests == 3 && err != nil {
+			t.Errorf("status %d: retried %s returned error: %s", test.status, (*requests)[0].Method, err)
+		}
+		if test.requests == 1 && err == nil {
+			t.Errorf("status %d: %s returned no error", test.status, (*requests)[0].Method)
+		}
+		for _, request := range *requests {
+			if request.Body != (*requests)[0].Body {
+				t.Errorf("status %d: retried with body %q, expected %q", test.status, request.Body, (*requests)[0].Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package resell
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// FloatingIP is a floating IP bought for a project. It's a Neutron
+// floating IP with the same id.
+type FloatingIP struct {
+	ID                string `json:"id"`
+	FloatingIPAddress string `json:"floating_ip_address"`
+	FixedIPAddress    string `json:"fixed_ip_address"`
+	PortID            string `json:"port_id"`
+	ProjectID         string `json:"project_id"`
+	Region            string `json:"region"`
+	Status            string `json:"status"`
+}
+
+type FloatingIPCreateOpts struct {
+	Region   string `json:"region"`
+	Quantity int    `json:"quantity"`
+}
+
+func (client *GenericClient) ListFloatingIPs() ([]FloatingIP, error) {
+	var result struct {
+		FloatingIPs []FloatingIP `json:"floatingips"`
+	}
+	if err := client.request(http.MethodGet, "/floatingips", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.FloatingIPs, nil
+}
+
+func (client *GenericClient) GetFloatingIP(floatingIPID string) (*FloatingIP, error) {
+	var result struct {
+		FloatingIP *FloatingIP `json:"floatingip"`
+	}
+	if err := client.request(http.MethodGet, "/floatingips/"+url.PathEscape(floatingIPID), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.FloatingIP, nil
+}
+
+// CreateFloatingIPs buys floating IPs for the project, they are
+// billed until deleted.
+func (client *GenericClient) CreateFloatingIPs(projectID string, opts FloatingIPCreateOpts) ([]FloatingIP, error) {
+	if opts.Quantity == 0 {
+		opts.Quantity = 1
+	}
+	body := map[string]interface{}{"floatingips": []FloatingIPCreateOpts{opts}}
+
+	var result struct {
+		FloatingIPs []FloatingIP `json:"floatingips"`
+	}
+	if err := client.request(http.MethodPost, "/floatingips/projects/"+url.PathEscape(projectID), body, &result); err != nil {
+		return nil, err
+	}
+	return result.FloatingIPs, nil
+}
+
+func (client *GenericClient) DeleteFloatingIP(floatingIPID string) error {
+	return client.request(http.MethodDelete, "/floatingips/"+url.PathEscape(floatingIPID), nil, nil)
+}
@@ -0,0 +1,60 @@
+package resell
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Project is a project of the Selectel VPC, which is an OpenStack project
+// together with its panel settings.
+type Project struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	CustomURL string `json:"custom_url"`
+	Enabled   bool   `json:"enabled"`
+	Quotas    Quotas `json:"quotas,omitempty"`
+}
+
+type ProjectCreateOpts struct {
+	Name string `json:"name"`
+
+	// Quotas are set on the new project if given.
+	Quotas Quotas `json:"quotas,omitempty"`
+}
+
+func (client *GenericClient) ListProjects() ([]Project, error) {
+	var result struct {
+		Projects []Project `json:"projects"`
+	}
+	if err := client.request(http.MethodGet, "/projects", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Projects, nil
+}
+
+func (client *GenericClient) GetProject(projectID string) (*Project, error) {
+	var result struct {
+		Project *Project `json:"project"`
+	}
+	if err := client.request(http.MethodGet, "/projects/"+url.PathEscape(projectID), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Project, nil
+}
+
+func (client *GenericClient) CreateProject(opts ProjectCreateOpts) (*Project, error) {
+	body := map[string]interface{}{"project": opts}
+
+	var result struct {
+		Project *Project `json:"project"`
+	}
+	if err := client.request(http.MethodPost, "/projects", body, &result); err != nil {
+		return nil, err
+	}
+	return result.Project, nil
+}
+
+func (client *GenericClient) DeleteProject(projectID string) error {
+	return client.request(http.MethodDelete, "/projects/"+url.PathEscape(projectID), nil, nil)
+}
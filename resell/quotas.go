@@ -0,0 +1,42 @@
+package resell
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Quotas are limits of resources like "compute_cores" or "network_floatingips"
+// by their region and zone.
+type Quotas map[string][]ResourceQuota
+
+// ResourceQuota is a limit of a resource in a region, and in a zone for
+// zonal resources. Used is returned only for quotas of a project.
+type ResourceQuota struct {
+	Region string `json:"region"`
+	Zone   string `json:"zone,omitempty"`
+	Value  int    `json:"value"`
+	Used   int    `json:"used,omitempty"`
+}
+
+func (client *GenericClient) GetProjectQuotas(projectID string) (Quotas, error) {
+	var result struct {
+		Quotas Quotas `json:"quotas"`
+	}
+	if err := client.request(http.MethodGet, "/quotas/projects/"+url.PathEscape(projectID), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Quotas, nil
+}
+
+// UpdateProjectQuotas changes the given quotas, others are left as is.
+func (client *GenericClient) UpdateProjectQuotas(projectID string, quotas Quotas) (Quotas, error) {
+	body := map[string]interface{}{"quotas": quotas}
+
+	var result struct {
+		Quotas Quotas `json:"quotas"`
+	}
+	if err := client.request(http.MethodPatch, "/quotas/projects/"+url.PathEscape(projectID), body, &result); err != nil {
+		return nil, err
+	}
+	return result.Quotas, nil
+}
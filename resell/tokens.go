@@ -0,0 +1,23 @@
+package resell
+
+import "net/http"
+
+// Token is a Keystone token scoped to a project, issued without
+// OpenStack credentials.
+type Token struct {
+	ID string `json:"id"`
+}
+
+func (client *GenericClient) CreateToken(projectID string) (*Token, error) {
+	body := map[string]interface{}{
+		"token": map[string]string{"project_id": projectID},
+	}
+
+	var result struct {
+		Token *Token `json:"token"`
+	}
+	if err := client.request(http.MethodPost, "/tokens", body, &result); err != nil {
+		return nil, err
+	}
+	return result.Token, nil
+}